@@ -0,0 +1,16 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Backend is a store shared by several processes, a second tier behind a
+// Cache. The implementations must be safe for concurrent use.
+type Backend interface {
+	// Get returns the value of the key, false if it does not exist or has expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set sets the value which expires after ttl, 0 means never expire.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,361 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/clock/fakeclock"
+)
+
+// redisServer is a minimal in-memory server of the Redis protocol with the
+// commands used by RedisBackend.
+type redisServer struct {
+	listener net.Listener
+	password string
+
+	lock      sync.Mutex
+	values    map[string][]byte
+	expiresAt map[string]time.Time
+	commands  []string
+	conns     []net.Conn
+	// delay is waited before every reply
+	delay time.Duration
+}
+
+func newRedisServer(t *testing.T, password string) *redisServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expected nil, but received %v", err)
+	}
+
+	s := &redisServer{
+		listener:  listener,
+		password:  password,
+		values:    map[string][]byte{},
+		expiresAt: map[string]time.Time{},
+	}
+	go s.serve()
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	return s
+}
+
+func (s *redisServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.lock.Lock()
+		s.conns = append(s.conns, conn)
+		s.lock.Unlock()
+		go s.handle(conn)
+	}
+}
+
+// closeConns closes the connections like a server restart or an idle timeout.
+func (s *redisServer) closeConns() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *redisServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	authenticated := s.password == ""
+	for {
+		args, err := readRedisCommand(reader)
+		if err != nil {
+			return
+		}
+
+		command := strings.ToUpper(string(args[0]))
+		s.lock.Lock()
+		s.commands = append(s.commands, command)
+		delay := s.delay
+		s.lock.Unlock()
+		time.Sleep(delay)
+
+		var reply string
+		switch {
+		case command == "AUTH":
+			authenticated = string(args[1]) == s.password
+			reply = "+OK\r\n"
+			if !authenticated {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authenticated:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case command == "GET":
+			reply = s.get(string(args[1]))
+		case command == "SET":
+			s.set(args)
+			reply = "+OK\r\n"
+		case command == "DEL":
+			reply = s.del(string(args[1]))
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+
+		_, err = io.WriteString(conn, reply)
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *redisServer) get(key string) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	value, found := s.values[key]
+	if !found {
+		return "$-1\r\n"
+	}
+	if expiresAt, found := s.expiresAt[key]; found && !time.Now().Before(expiresAt) {
+		delete(s.values, key)
+		delete(s.expiresAt, key)
+		return "$-1\r\n"
+	}
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+}
+
+func (s *redisServer) set(args [][]byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := string(args[1])
+	s.values[key] = args[2]
+	delete(s.expiresAt, key)
+	if len(args) == 5 && strings.ToUpper(string(args[3])) == "PX" {
+		milliseconds, _ := strconv.Atoi(string(args[4]))
+		s.expiresAt[key] = time.Now().Add(time.Duration(milliseconds) * time.Millisecond)
+	}
+}
+
+func (s *redisServer) del(key string) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, found := s.values[key]; !found {
+		return ":0\r\n"
+	}
+	delete(s.values, key)
+	delete(s.expiresAt, key)
+	return ":1\r\n"
+}
+
+func readRedisCommand(reader *bufio.Reader) ([][]byte, error) {
+	line, err := readRedisLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("expected an array, but received %s", line)
+	}
+	count, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([][]byte, count)
+	for i := range args {
+		reply, err := readRedisReply(reader)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = reply.([]byte)
+	}
+	return args, nil
+}
+
+func testBackend(t *testing.T, backend Backend) {
+	ctx := context.Background()
+
+	_, found, err := backend.Get(ctx, "foo")
+	if err != nil || found {
+		t.Errorf("expected not found, but received %v, %v", found, err)
+	}
+
+	values := map[string][]byte{
+		"foo":           []byte("value0"),
+		"app/env/name":  []byte("{\"a\": \"b\\r\\n\"}"),
+		"binary":        {0, 1, 2, '\r', '\n', 255},
+		"empty":         {},
+		"bar\r\nspaces": []byte("value1"),
+	}
+	for key, value := range values {
+		if err := backend.Set(ctx, key, value, 0); err != nil {
+			t.Errorf("expected nil, but received %v", err)
+		}
+	}
+	for key, value := range values {
+		received, found, err := backend.Get(ctx, key)
+		if err != nil || !found {
+			t.Errorf("%s, expected found, but received %v, %v", key, found, err)
+		}
+		if e, a := value, received; !reflect.DeepEqual(e, a) {
+			t.Errorf("%s, expected %v, but received %v", key, e, a)
+		}
+	}
+
+	if err := backend.Set(ctx, "foo", []byte("value2"), 0); err != nil {
+		t.Errorf("expected nil, but received %v", err)
+	}
+	if value, _, _ := backend.Get(ctx, "foo"); string(value) != "value2" {
+		t.Errorf("expected %v, but received %s", "value2", value)
+	}
+
+	if err := backend.Delete(ctx, "foo"); err != nil {
+		t.Errorf("expected nil, but received %v", err)
+	}
+	if err := backend.Delete(ctx, "foo"); err != nil {
+		t.Errorf("expected nil, but received %v", err)
+	}
+	if _, found, _ := backend.Get(ctx, "foo"); found {
+		t.Errorf("expected %v, but received %v", false, found)
+	}
+
+	if err := backend.Set(ctx, "ttl", []byte("value3"), time.Millisecond*20); err != nil {
+		t.Errorf("expected nil, but received %v", err)
+	}
+	if _, found, _ := backend.Get(ctx, "ttl"); !found {
+		t.Errorf("expected %v, but received %v", true, found)
+	}
+	time.Sleep(time.Millisecond * 30)
+	if _, found, _ := backend.Get(ctx, "ttl"); found {
+		t.Errorf("expected %v, but received %v", false, found)
+	}
+}
+
+func TestFileBackend(t *testing.T) {
+	backend, err := NewFileBackend(t.TempDir())
+	if err != nil {
+		t.Fatalf("expected nil, but received %v", err)
+	}
+	testBackend(t, backend)
+}
+
+func TestFileBackend_Clock(t *testing.T) {
+	clk := fakeclock.New(time.Now())
+	backend, err := NewFileBackend(t.TempDir(), WithFileClock(clk))
+	if err != nil {
+		t.Fatalf("expected nil, but received %v", err)
+	}
+
+	ctx := context.Background()
+	if err := backend.Set(ctx, "ttl", []byte("value0"), time.Hour); err != nil {
+		t.Errorf("expected nil, but received %v", err)
+	}
+	clk.Advance(time.Minute * 59)
+	if _, found, _ := backend.Get(ctx, "ttl"); !found {
+		t.Errorf("expected %v, but received %v", true, found)
+	}
+	clk.Advance(time.Minute)
+	if _, found, _ := backend.Get(ctx, "ttl"); found {
+		t.Errorf("expected %v, but received %v", false, found)
+	}
+}
+
+func TestRedisBackend(t *testing.T) {
+	server := newRedisServer(t, "")
+	backend := NewRedisBackend(server.listener.Addr().String())
+	defer backend.Close()
+
+	testBackend(t, backend)
+}
+
+func TestRedisBackend_Auth(t *testing.T) {
+	server := newRedisServer(t, "secret")
+
+	backend := NewRedisBackend(server.listener.Addr().String(), WithRedisPassword("wrong"))
+	if err := backend.Set(context.Background(), "foo", []byte("value0"), 0); err == nil {
+		t.Errorf("expected an error, but received nil")
+	}
+
+	backend = NewRedisBackend(server.listener.Addr().String(), WithRedisPassword("secret"), WithRedisMaxIdle(1))
+	defer backend.Close()
+	for i := 0; i < 3; i++ {
+		if err := backend.Set(context.Background(), "foo", []byte("value0"), 0); err != nil {
+			t.Errorf("expected nil, but received %v", err)
+		}
+	}
+
+	// the wrong password is refused, then the connection of the second backend
+	// is reused, so it sends AUTH once
+	server.lock.Lock()
+	commands := server.commands
+	server.lock.Unlock()
+	expected := []string{"AUTH", "AUTH", "SET", "SET", "SET"}
+	if e, a := expected, commands; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestRedisBackend_ClosedConn(t *testing.T) {
+	server := newRedisServer(t, "")
+	backend := NewRedisBackend(server.listener.Addr().String())
+	defer backend.Close()
+
+	if err := backend.Set(context.Background(), "foo", []byte("value0"), 0); err != nil {
+		t.Fatalf("expected nil, but received %v", err)
+	}
+
+	// the idle connection has been closed by the server, GET is sent again
+	server.closeConns()
+	value, found, err := backend.Get(context.Background(), "foo")
+	if err != nil {
+		t.Errorf("expected nil, but received %v", err)
+	}
+	if !found || string(value) != "value0" {
+		t.Errorf("expected %v, but received %v", "value0", string(value))
+	}
+}
+
+func TestRedisBackend_Cancel(t *testing.T) {
+	server := newRedisServer(t, "")
+	server.delay = time.Second
+	backend := NewRedisBackend(server.listener.Addr().String())
+	defer backend.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(time.Millisecond*20, cancel)
+	start := time.Now()
+	_, _, err := backend.Get(ctx, "foo")
+	if e, a := context.Canceled, err; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if elapsed := time.Since(start); elapsed > time.Millisecond*500 {
+		t.Errorf("expected the command to be interrupted, but it took %v", elapsed)
+	}
+}
+
+func TestRedisBackend_Unavailable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expected nil, but received %v", err)
+	}
+	address := listener.Addr().String()
+	_ = listener.Close()
+
+	backend := NewRedisBackend(address, WithRedisTimeout(time.Millisecond*100))
+	if _, _, err := backend.Get(context.Background(), "foo"); err == nil {
+		t.Errorf("expected an error, but received nil")
+	}
+}
@@ -1,90 +1,414 @@
 package cache
 
 import (
+	"hash/maphash"
 	"sync"
 	"sync/atomic"
+	"time"
 
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/clock"
 	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/logger"
 )
 
-type Cache struct {
-	caches     sync.Map
+type Cache[K comparable, V any] struct {
+	// shards partition the keys by hash, each has its own lock, entries and
+	// policy; there is a single shard by default
+	shards         []*shard[K, V]
+	seed           maphash.Seed
+	evictionPolicy EvictionPolicy
+
+	// limitLock guards cacheLimit and maxWeight, which are the sums of the
+	// limits of the shards
+	limitLock  sync.Mutex
 	cacheLimit int64
+	maxWeight  int64
+
 	// size is used to count the number elements in the cache.
-	// The atomic package is used to ensure this size is accurate when
-	// using multiple goroutines.
+	// It is only changed with the lock of a shard held, the atomic package is
+	// used so that it can be read without any lock.
 	size int64
+	// weight is the total weight, maintained like size
+	weight int64
+
+	clock  clock.Clock
+	logger logger.Holder
+	// metrics holds the *cacheMetrics set by SetMetrics, nil when not recorded
+	metrics atomic.Value
+	// defaultTTL is the ttl of the entries added by Add, 0 means never expire
+	defaultTTL  time.Duration
+	janitorStop chan struct{}
+	closeOnce   sync.Once
+
+	onEvict     []func(key K, value V, reason EvictionReason)
+	onEvictLock sync.RWMutex
+
+	// encode and decode are set by SetCodec for Snapshot and Restore
+	encode    func(key K, value V) ([]byte, error)
+	decode    func(key K, data []byte) (V, error)
+	codecLock sync.RWMutex
+
+	counters counters
+
+	// refreshAheadFraction is the fraction of the ttl after which GetOrLoad
+	// reloads a value in the background, 0 means never
+	refreshAheadFraction float64
+	// loadTimeout bounds the Loader calls
+	loadTimeout time.Duration
+	inFlight[K, V]
 }
 
-func New(cacheLimit int64) *Cache {
-	return &Cache{
-		cacheLimit: cacheLimit,
-		caches:     sync.Map{},
+type entry[V any] struct {
+	value     V
+	weight    int64
+	addedAt   time.Time
+	expiresAt time.Time
+}
+
+// Meta is the metadata of a cached entry.
+type Meta struct {
+	AddedAt time.Time
+	// ExpiresAt is zero if the entry never expires
+	ExpiresAt time.Time
+}
+
+// EvictionReason tells why a value has been removed from the cache.
+type EvictionReason int
+
+const (
+	// ReasonCapacity means the cache has exceeded its limit.
+	ReasonCapacity EvictionReason = iota
+	// ReasonExpired means the ttl of the entry has passed.
+	ReasonExpired
+	// ReasonDeleted means Delete has been called.
+	ReasonDeleted
+	// ReasonReplaced means a new value has been added with the same key,
+	// the key is still in the cache.
+	ReasonReplaced
+)
+
+func (r EvictionReason) String() string {
+	switch r {
+	case ReasonCapacity:
+		return "capacity"
+	case ReasonExpired:
+		return "expired"
+	case ReasonDeleted:
+		return "deleted"
+	case ReasonReplaced:
+		return "replaced"
 	}
+	return "unknown"
+}
+
+// eviction is a removed value whose callbacks are called after the lock is released.
+type eviction[K comparable, V any] struct {
+	key    K
+	value  V
+	reason EvictionReason
 }
 
-func (c *Cache) Get(cacheKey interface{}) (interface{}, bool) {
-	cacheValue, found := c.caches.Load(cacheKey)
-	return cacheValue, found
+func (e *entry[V]) isExpired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
 }
 
-func (c *Cache) Add(cacheKey, cacheValue interface{}) {
-	_, loaded := c.caches.LoadOrStore(cacheKey, cacheValue)
-	if loaded {
-		// 原来存在这个 Key
-		c.caches.Store(cacheKey, cacheValue)
-	} else {
-		// 原本不存在这个 key
-		size := atomic.AddInt64(&c.size, 1)
-		if size > 0 && size > c.cacheLimit {
-			c.deleteRandomKey(cacheKey)
+func New[K comparable, V any](cacheLimit int64, opts ...Option) *Cache[K, V] {
+	o := options{
+		evictionPolicy: defaultEvictionPolicy,
+		shards:         1,
+		clock:          clock.New(),
+		loadTimeout:    defaultLoadTimeout,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.shards < 1 {
+		o.shards = 1
+	}
+	if o.loadTimeout <= 0 {
+		o.loadTimeout = defaultLoadTimeout
+	}
+
+	c := &Cache[K, V]{
+		shards:         make([]*shard[K, V], o.shards),
+		seed:           maphash.MakeSeed(),
+		evictionPolicy: o.evictionPolicy,
+		cacheLimit:     cacheLimit,
+		maxWeight:      o.maxWeight,
+		clock:          o.clock,
+		defaultTTL:     o.defaultTTL,
+
+		refreshAheadFraction: o.refreshAheadFraction,
+		loadTimeout:          o.loadTimeout,
+	}
+	c.logger.Set(o.logger)
+	c.metrics.Store(newCacheMetrics(o.metrics, o.metricsName))
+	for i := range c.shards {
+		c.shards[i] = newShard(c, o.evictionPolicy, splitLimit(cacheLimit, i, o.shards), splitLimit(o.maxWeight, i, o.shards))
+	}
+
+	if o.janitorInterval > 0 {
+		c.janitorStop = make(chan struct{})
+		go c.runJanitor(o.janitorInterval)
+	}
+
+	return c
+}
+
+func (c *Cache[K, V]) shard(key K) *shard[K, V] {
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	return c.shards[hashKey(c.seed, key)%uint64(len(c.shards))]
+}
+
+// SetLogger replaces the logger of the cache, nil means the default logger.
+func (c *Cache[K, V]) SetLogger(l logger.Logger) {
+	c.logger.Set(l)
+}
+
+// OnEvict registers a callback called when a value is removed from the cache
+// or replaced. It is called after the cache lock is released, so it may use
+// the cache.
+func (c *Cache[K, V]) OnEvict(callback func(key K, value V, reason EvictionReason)) {
+	c.onEvictLock.Lock()
+	defer c.onEvictLock.Unlock()
+
+	c.onEvict = append(c.onEvict, callback)
+}
+
+func (c *Cache[K, V]) notify(evictions []eviction[K, V]) {
+	c.recordMetrics(evictions)
+	if len(evictions) == 0 {
+		return
+	}
+
+	c.onEvictLock.RLock()
+	callbacks := c.onEvict
+	c.onEvictLock.RUnlock()
+
+	for _, e := range evictions {
+		for _, callback := range callbacks {
+			callback(e.key, e.value, e.reason)
 		}
 	}
 }
 
-func (c *Cache) deleteRandomKey(butNotKey interface{}) {
-	if atomic.LoadInt64(&c.size) > 0 && atomic.LoadInt64(&c.size) > c.cacheLimit {
-		c.caches.Range(func(key, value interface{}) bool {
-			if butNotKey != nil && key == butNotKey {
-				// 不删除刚刚添加的
-				return true
-			}
-			if atomic.LoadInt64(&c.size) > 0 && atomic.LoadInt64(&c.size) > c.cacheLimit {
-				logger.Warn("exceed the cache limit [", c.cacheLimit, "] delete random key [", key, "]")
-				c.Delete(key)
-				return true
-			}
-			return false
-		})
+func (c *Cache[K, V]) Get(cacheKey K) (V, bool) {
+	value, _, found := c.GetWithMeta(cacheKey)
+	return value, found
+}
+
+// GetWithMeta returns the value with the time it has been added and the time
+// it expires. An expired entry is deleted and not returned.
+func (c *Cache[K, V]) GetWithMeta(cacheKey K) (V, Meta, bool) {
+	s := c.shard(cacheKey)
+
+	s.lock.Lock()
+	e, evictions := s.get(cacheKey, c.clock.Now(), nil)
+	var value V
+	var meta Meta
+	if e != nil {
+		value = e.value
+		meta = Meta{AddedAt: e.addedAt, ExpiresAt: e.expiresAt}
 	}
+	s.lock.Unlock()
+
+	c.notify(evictions)
+
+	if e == nil {
+		atomic.AddInt64(&c.counters.misses, 1)
+		return value, meta, false
+	}
+	atomic.AddInt64(&c.counters.hits, 1)
+	return value, meta, true
 }
 
-func (c *Cache) Delete(key interface{}) {
-	_, loaded := c.caches.LoadAndDelete(key)
-	if loaded {
-		atomic.AddInt64(&c.size, -1)
+// Peek returns the value like Get, but it neither counts a hit or a miss nor
+// tells the eviction policy the value has been used, so that refreshing the
+// cache does not keep the values in it.
+func (c *Cache[K, V]) Peek(cacheKey K) (V, bool) {
+	value, _, found := c.PeekWithMeta(cacheKey)
+	return value, found
+}
+
+// PeekWithMeta is GetWithMeta without the effects of Get, see Peek. An expired
+// entry is not returned, it is left to Get and the janitor to delete it.
+func (c *Cache[K, V]) PeekWithMeta(cacheKey K) (V, Meta, bool) {
+	s := c.shard(cacheKey)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var value V
+	e, found := s.entries[cacheKey]
+	if !found || e.isExpired(c.clock.Now()) {
+		return value, Meta{}, false
 	}
+	return e.value, Meta{AddedAt: e.addedAt, ExpiresAt: e.expiresAt}, true
 }
 
-func (c *Cache) Keys() []interface{} {
-	var keys []interface{}
-	c.caches.Range(func(key, value interface{}) bool {
-		keys = append(keys, key)
-		return true
-	})
+// Add adds the value with the default ttl.
+func (c *Cache[K, V]) Add(cacheKey K, cacheValue V) {
+	c.AddWithTTL(cacheKey, cacheValue, c.defaultTTL)
+}
+
+// AddWithTTL adds the value which expires after ttl, 0 means never expire.
+func (c *Cache[K, V]) AddWithTTL(cacheKey K, cacheValue V, ttl time.Duration) {
+	now := c.clock.Now()
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = now.Add(ttl)
+	}
+
+	s := c.shard(cacheKey)
+	s.lock.Lock()
+	evictions := s.add(cacheKey, cacheValue, now, expiresAt)
+	s.lock.Unlock()
+
+	c.notify(evictions)
+}
+
+func (c *Cache[K, V]) Delete(key K) {
+	s := c.shard(key)
+	s.lock.Lock()
+	evictions := s.delete(key, ReasonDeleted, nil)
+	s.lock.Unlock()
+
+	c.notify(evictions)
+}
+
+// Keys returns the keys that have not expired.
+func (c *Cache[K, V]) Keys() []K {
+	now := c.clock.Now()
+
+	keys := make([]K, 0, c.Len())
+	for _, s := range c.shards {
+		s.lock.Lock()
+		for key, e := range s.entries {
+			if !e.isExpired(now) {
+				keys = append(keys, key)
+			}
+		}
+		s.lock.Unlock()
+	}
 	return keys
 }
 
-func (c *Cache) UpdateCacheLimit(cacheLimit int64) int64 {
-	oldCacheLimit := atomic.SwapInt64(&c.cacheLimit, cacheLimit)
+// DeleteExpired deletes all the expired entries, which is done periodically
+// by the janitor when WithJanitor is used.
+func (c *Cache[K, V]) DeleteExpired() {
+	now := c.clock.Now()
+	var evictions []eviction[K, V]
+
+	for _, s := range c.shards {
+		s.lock.Lock()
+		evictions = s.deleteExpired(now, evictions)
+		s.lock.Unlock()
+	}
+
+	c.notify(evictions)
+}
+
+func (c *Cache[K, V]) runJanitor(interval time.Duration) {
+	janitorTicker := c.clock.NewTicker(interval)
+	defer janitorTicker.Stop()
 
-	if atomic.LoadInt64(&c.size) > 0 && atomic.LoadInt64(&c.size) > c.cacheLimit {
-		c.deleteRandomKey(nil)
+	for {
+		select {
+		case <-janitorTicker.C():
+			c.DeleteExpired()
+		case <-c.janitorStop:
+			return
+		}
 	}
+}
+
+// Close stops the janitor, the cache can still be used.
+func (c *Cache[K, V]) Close() {
+	c.closeOnce.Do(func() {
+		if c.janitorStop != nil {
+			close(c.janitorStop)
+		}
+	})
+}
+
+// Len returns the number of elements in the cache, including the expired ones
+// that have not been deleted yet.
+func (c *Cache[K, V]) Len() int64 {
+	return atomic.LoadInt64(&c.size)
+}
+
+func (c *Cache[K, V]) UpdateCacheLimit(cacheLimit int64) int64 {
+	c.limitLock.Lock()
+	oldCacheLimit := c.cacheLimit
+	c.cacheLimit = cacheLimit
+	evictions := c.resize()
+	c.limitLock.Unlock()
+
+	c.notify(evictions)
 
 	return oldCacheLimit
 }
 
-func (c *Cache) CacheLimit() int64 {
+func (c *Cache[K, V]) CacheLimit() int64 {
+	c.limitLock.Lock()
+	defer c.limitLock.Unlock()
+
 	return c.cacheLimit
 }
+
+// SetWeigher sets the function that weighs the values against the max weight
+// set by WithMaxWeight, e.g. their size in bytes. The values already in the
+// cache are weighed again.
+func (c *Cache[K, V]) SetWeigher(weigher func(key K, value V) int64) {
+	var evictions []eviction[K, V]
+	for _, s := range c.shards {
+		s.lock.Lock()
+		evictions = append(evictions, s.setWeigher(weigher)...)
+		s.lock.Unlock()
+	}
+
+	c.notify(evictions)
+}
+
+// Weight returns the total weight of the values in the cache.
+func (c *Cache[K, V]) Weight() int64 {
+	return atomic.LoadInt64(&c.weight)
+}
+
+// UpdateMaxWeight sets the max weight, 0 means no weight limit, and returns the old one.
+func (c *Cache[K, V]) UpdateMaxWeight(maxWeight int64) int64 {
+	c.limitLock.Lock()
+	oldMaxWeight := c.maxWeight
+	c.maxWeight = maxWeight
+	evictions := c.resize()
+	c.limitLock.Unlock()
+
+	c.notify(evictions)
+
+	return oldMaxWeight
+}
+
+func (c *Cache[K, V]) MaxWeight() int64 {
+	c.limitLock.Lock()
+	defer c.limitLock.Unlock()
+
+	return c.maxWeight
+}
+
+// Shards returns the number of shards.
+func (c *Cache[K, V]) Shards() int {
+	return len(c.shards)
+}
+
+// resize splits the limits between the shards, it must be called with
+// limitLock held.
+func (c *Cache[K, V]) resize() []eviction[K, V] {
+	var evictions []eviction[K, V]
+	for i, s := range c.shards {
+		s.lock.Lock()
+		evictions = append(evictions, s.resize(splitLimit(c.cacheLimit, i, len(c.shards)), splitLimit(c.maxWeight, i, len(c.shards)))...)
+		s.lock.Unlock()
+	}
+	return evictions
+}
@@ -1,8 +1,22 @@
 package cache
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/clock/fakeclock"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/metrics"
 )
 
 type cacheEntity struct {
@@ -116,7 +130,7 @@ func TestCache_Add(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		cache := New(c.limit)
+		cache := New[string, cacheEntity](c.limit)
 
 		for _, entity := range c.cacheEntities {
 			cache.Add(entity.Key, entity)
@@ -124,12 +138,12 @@ func TestCache_Add(t *testing.T) {
 
 		count := 0
 		cacheEntities := map[string]cacheEntity{}
-		cache.caches.Range(func(key, value interface{}) bool {
+		for key, item := range cache.shards[0].entries {
+			value := item.value
 			count++
 
-			cacheEntities[key.(string)] = value.(cacheEntity)
-			return true
-		})
+			cacheEntities[key] = value
+		}
 
 		if e, a := c.expectedSize, cache.size; int64(e) != a {
 			t.Errorf("expected %v, but received %v", e, a)
@@ -254,27 +268,26 @@ func TestCache_Get(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		cache := New(c.limit)
+		cache := New[string, cacheEntity](c.limit)
 
 		for _, entity := range c.cacheEntities {
 			cache.Add(entity.Key, entity)
 		}
 
 		var keys []string
-		cache.caches.Range(func(key, value interface{}) bool {
-			a := value.(cacheEntity)
-			e, ok := c.validKeys[key.(string)]
+		for key, item := range cache.shards[0].entries {
+			a := item.value
+			e, ok := c.validKeys[key]
 			if !ok {
-				t.Errorf("unrecognized key %q in cache", key.(string))
+				t.Errorf("unrecognized key %q in cache", key)
 			}
 
 			if !reflect.DeepEqual(e, a) {
 				t.Errorf("expected %v, but received %v", e, a)
 			}
 
-			keys = append(keys, key.(string))
-			return true
-		})
+			keys = append(keys, key)
+		}
 
 		for _, key := range keys {
 			a, ok := cache.Get(key)
@@ -374,7 +387,7 @@ func TestCache_Delete(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		cache := New(c.limit)
+		cache := New[string, cacheEntity](c.limit)
 
 		for _, entity := range c.cacheEntities {
 			cache.Add(entity.Key, entity)
@@ -386,22 +399,21 @@ func TestCache_Delete(t *testing.T) {
 
 		count := 0
 		var keys []string
-		cache.caches.Range(func(key, value interface{}) bool {
+		for key, item := range cache.shards[0].entries {
 			count++
 
-			a := value.(cacheEntity)
-			e, ok := c.validKeys[key.(string)]
+			a := item.value
+			e, ok := c.validKeys[key]
 			if !ok {
-				t.Errorf("unrecognized key %q in cache", key.(string))
+				t.Errorf("unrecognized key %q in cache", key)
 			}
 
 			if !reflect.DeepEqual(e, a) {
 				t.Errorf("expected %v, but received %v", e, a)
 			}
 
-			keys = append(keys, key.(string))
-			return true
-		})
+			keys = append(keys, key)
+		}
 
 		if e, a := c.expectedSize, cache.size; int64(e) != a {
 			t.Errorf("expected %v, but received %v", e, a)
@@ -528,7 +540,7 @@ func TestCache_Keys(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		cache := New(c.limit)
+		cache := New[string, cacheEntity](c.limit)
 
 		for _, entity := range c.cacheEntities {
 			cache.Add(entity.Key, entity)
@@ -539,12 +551,12 @@ func TestCache_Keys(t *testing.T) {
 		for _, key := range keys {
 			a, ok := cache.Get(key)
 			if !ok {
-				t.Errorf("expected key to be present: %q", key.(string))
+				t.Errorf("expected key to be present: %q", key)
 			}
 
-			e, ok := c.validKeys[key.(string)]
+			e, ok := c.validKeys[key]
 			if !ok {
-				t.Errorf("unrecognized key %q in cache", key.(string))
+				t.Errorf("unrecognized key %q in cache", key)
 			}
 
 			if !reflect.DeepEqual(e, a) {
@@ -761,7 +773,7 @@ func TestCache_UpdateLimit(t *testing.T) {
 	}
 
 	for i, c := range cases {
-		cache := New(c.limit)
+		cache := New[string, cacheEntity](c.limit)
 
 		for _, entity := range c.cacheEntities {
 			cache.Add(entity.Key, entity)
@@ -775,12 +787,12 @@ func TestCache_UpdateLimit(t *testing.T) {
 
 		count := 0
 		cacheEntities := map[string]cacheEntity{}
-		cache.caches.Range(func(key, value interface{}) bool {
+		for key, item := range cache.shards[0].entries {
+			value := item.value
 			count++
 
-			cacheEntities[key.(string)] = value.(cacheEntity)
-			return true
-		})
+			cacheEntities[key] = value
+		}
 
 		if e, a := c.expectedSize, cache.size; int64(e) != a {
 			t.Errorf("case %d, expected %v, but received %v", i, e, a)
@@ -801,3 +813,910 @@ func TestCache_UpdateLimit(t *testing.T) {
 		}
 	}
 }
+
+func TestCache_EvictionPolicy(t *testing.T) {
+	cases := []struct {
+		evictionPolicy EvictionPolicy
+		limit          int64
+		// a positive number adds the key, a negative number reads the key
+		operations   []int
+		expectedKeys []int
+	}{
+		{
+			evictionPolicy: LRU,
+			limit:          3,
+			operations:     []int{1, 2, 3, -1, 4},
+			expectedKeys:   []int{1, 3, 4},
+		},
+		{
+			evictionPolicy: FIFO,
+			limit:          3,
+			operations:     []int{1, 2, 3, -1, 4},
+			expectedKeys:   []int{2, 3, 4},
+		},
+		{
+			evictionPolicy: LFU,
+			limit:          3,
+			operations:     []int{1, 2, 3, -1, -1, -2, -3, -3, 4, 5},
+			expectedKeys:   []int{1, 3, 5},
+		},
+		{
+			// 4 is not admitted until it is used more frequently than the LRU key
+			evictionPolicy: TinyLFU,
+			limit:          3,
+			operations:     []int{1, 2, 3, -1, -2, -3, 4, 4},
+			expectedKeys:   []int{1, 2, 3},
+		},
+		{
+			evictionPolicy: TinyLFU,
+			limit:          3,
+			operations:     []int{1, 2, 3, -1, -3, 4, 4},
+			expectedKeys:   []int{1, 3, 4},
+		},
+	}
+
+	for i, c := range cases {
+		cache := New[int, int](c.limit, WithEvictionPolicy(c.evictionPolicy))
+		for _, operation := range c.operations {
+			if operation > 0 {
+				cache.Add(operation, operation)
+			} else {
+				cache.Get(-operation)
+			}
+		}
+
+		keys := cache.Keys()
+		sort.Ints(keys)
+		if e, a := c.expectedKeys, keys; !reflect.DeepEqual(e, a) {
+			t.Errorf("case %d %v, expected %v, but received %v", i, c.evictionPolicy, e, a)
+		}
+		if e, a := int64(len(c.expectedKeys)), cache.Len(); e != a {
+			t.Errorf("case %d %v, expected %v, but received %v", i, c.evictionPolicy, e, a)
+		}
+	}
+}
+
+func TestCache_TTL(t *testing.T) {
+	clk := fakeclock.New(time.Now())
+	cache := New[string, string](5, WithDefaultTTL(time.Millisecond*50), WithClock(clk))
+	defer cache.Close()
+
+	before := clk.Now()
+	cache.Add("foo", "value0")
+	cache.AddWithTTL("bar", "value1", 0)
+	cache.AddWithTTL("baz", "value2", time.Hour)
+
+	value, meta, ok := cache.GetWithMeta("foo")
+	if !ok || value != "value0" {
+		t.Errorf("expected key to be present: %q", "foo")
+	}
+	if meta.AddedAt.Before(before) {
+		t.Errorf("expected added at after %v, but received %v", before, meta.AddedAt)
+	}
+	if e, a := meta.AddedAt.Add(time.Millisecond*50), meta.ExpiresAt; !e.Equal(a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	_, meta, _ = cache.GetWithMeta("bar")
+	if !meta.ExpiresAt.IsZero() {
+		t.Errorf("expected no expiry, but received %v", meta.ExpiresAt)
+	}
+
+	clk.Advance(time.Millisecond * 49)
+	if _, ok := cache.Get("foo"); !ok {
+		t.Errorf("expected key to be present: %q", "foo")
+	}
+
+	clk.Advance(time.Millisecond)
+	if _, ok := cache.Get("foo"); ok {
+		t.Errorf("expected key to be expired: %q", "foo")
+	}
+	for _, key := range []string{"bar", "baz"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("expected key to be present: %q", key)
+		}
+	}
+	if e, a := int64(2), cache.Len(); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestCache_Janitor(t *testing.T) {
+	cache := New[string, string](5, WithJanitor(time.Millisecond*10))
+	defer cache.Close()
+
+	cache.AddWithTTL("foo", "value0", time.Millisecond*20)
+	cache.AddWithTTL("bar", "value1", time.Millisecond*20)
+	cache.Add("baz", "value2")
+
+	if e, a := 3, len(cache.Keys()); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	time.Sleep(time.Millisecond * 60)
+
+	// deleted by the janitor, not by a read
+	if e, a := int64(1), cache.Len(); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := []string{"baz"}, cache.Keys(); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestCache_OnEvict(t *testing.T) {
+	type evicted struct {
+		key    string
+		value  string
+		reason EvictionReason
+	}
+
+	clk := fakeclock.New(time.Now())
+	cache := New[string, string](2, WithClock(clk))
+	var evictions []evicted
+	cache.OnEvict(func(key string, value string, reason EvictionReason) {
+		evictions = append(evictions, evicted{key: key, value: value, reason: reason})
+		// the lock has been released
+		cache.Keys()
+	})
+
+	cache.Add("foo", "value0")
+	cache.Add("foo", "value1")
+	cache.Add("bar", "value2")
+	cache.Add("baz", "value3")
+	cache.Delete("bar")
+	cache.Delete("bar")
+	cache.AddWithTTL("qux", "value4", time.Millisecond)
+	clk.Advance(time.Millisecond)
+	cache.Get("qux")
+	cache.Add("moo", "value5")
+	cache.Add("quux", "value6")
+	cache.UpdateCacheLimit(1)
+
+	expected := []evicted{
+		{key: "foo", value: "value0", reason: ReasonReplaced},
+		{key: "foo", value: "value1", reason: ReasonCapacity},
+		{key: "bar", value: "value2", reason: ReasonDeleted},
+		{key: "qux", value: "value4", reason: ReasonExpired},
+		{key: "baz", value: "value3", reason: ReasonCapacity},
+		{key: "moo", value: "value5", reason: ReasonCapacity},
+	}
+	if e, a := expected, evictions; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestCache_Stats(t *testing.T) {
+	cache := New[string, string](2)
+
+	cache.Add("foo", "value0")
+	cache.Add("foo", "value1")
+	cache.Add("bar", "value2")
+	cache.Add("baz", "value3")
+	cache.Get("foo")
+	cache.Get("bar")
+	cache.Get("baz")
+	cache.Get("qux")
+	cache.Delete("bar")
+
+	expected := Stats{
+		Hits:     2,
+		Misses:   2,
+		HitRatio: 0.5,
+		Evictions: map[EvictionReason]int64{
+			ReasonCapacity: 1,
+			ReasonExpired:  0,
+			ReasonDeleted:  1,
+		},
+		Adds:       3,
+		Replaces:   1,
+		Size:       1,
+		CacheLimit: 2,
+		Weight:     1,
+	}
+	if e, a := expected, cache.Stats(); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %+v, but received %+v", e, a)
+	}
+}
+
+func TestCache_Peek(t *testing.T) {
+	clk := fakeclock.New(time.Now())
+	cache := New[string, string](2, WithClock(clk))
+
+	cache.Add("foo", "value0")
+	cache.AddWithTTL("bar", "value1", time.Minute)
+	if value, found := cache.Peek("foo"); !found || value != "value0" {
+		t.Errorf("expected %v, but received %v %v", "value0", value, found)
+	}
+	if _, found := cache.Peek("qux"); found {
+		t.Errorf("expected qux not to be found")
+	}
+
+	// foo has only been peeked, it is still the least recently used
+	cache.Add("baz", "value2")
+	if _, found := cache.Peek("foo"); found {
+		t.Errorf("expected foo to be evicted")
+	}
+
+	_, meta, found := cache.PeekWithMeta("bar")
+	if e, a := clk.Now().Add(time.Minute), meta.ExpiresAt; !found || !e.Equal(a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	clk.Advance(time.Minute)
+	if _, found := cache.Peek("bar"); found {
+		t.Errorf("expected bar to be expired")
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 0 || stats.Misses != 0 {
+		t.Errorf("expected no hit and no miss, but received %d and %d", stats.Hits, stats.Misses)
+	}
+}
+
+// recordProvider records the last value of the gauges and the sums of the
+// counters by name and label values.
+type recordProvider struct {
+	lock   sync.Mutex
+	values map[string]float64
+}
+
+func (p *recordProvider) record(name string, value float64, labelValues []string, add bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	key := name + "{" + strings.Join(labelValues, ",") + "}"
+	if add {
+		value += p.values[key]
+	}
+	p.values[key] = value
+}
+
+type recordInstrument struct {
+	provider *recordProvider
+	name     string
+}
+
+func (i recordInstrument) Add(delta float64, labelValues ...string) {
+	i.provider.record(i.name, delta, labelValues, true)
+}
+
+func (i recordInstrument) Set(value float64, labelValues ...string) {
+	i.provider.record(i.name, value, labelValues, false)
+}
+
+func (i recordInstrument) Delete(...string) {}
+
+func (i recordInstrument) Observe(value float64, labelValues ...string) {
+	i.provider.record(i.name, value, labelValues, true)
+}
+
+func (p *recordProvider) Counter(name string, _ string, _ ...string) metrics.Counter {
+	return recordInstrument{provider: p, name: name}
+}
+
+func (p *recordProvider) Gauge(name string, _ string, _ ...string) metrics.Gauge {
+	return recordInstrument{provider: p, name: name}
+}
+
+func (p *recordProvider) Histogram(name string, _ string, _ ...string) metrics.Histogram {
+	return recordInstrument{provider: p, name: name}
+}
+
+func (p *recordProvider) GaugeFunc(string, string, metrics.GaugeFunc, ...string) func() {
+	return func() {}
+}
+
+func TestCache_Metrics(t *testing.T) {
+	provider := &recordProvider{values: map[string]float64{}}
+	clk := fakeclock.New(time.Now())
+	cache := New[string, string](2, WithClock(clk), WithMetrics(provider, "test"))
+
+	cache.Add("foo", "value0")
+	cache.Add("foo", "value1")
+	cache.Add("bar", "value2")
+	cache.Add("baz", "value3")
+	cache.Delete("bar")
+	cache.AddWithTTL("qux", "value4", time.Millisecond)
+	clk.Advance(time.Millisecond)
+	cache.Get("qux")
+
+	expected := map[string]float64{
+		"cache_size{test}":                     1,
+		"cache_evictions_total{test,capacity}": 1,
+		"cache_evictions_total{test,deleted}":  1,
+		"cache_evictions_total{test,expired}":  1,
+	}
+	if e, a := expected, provider.values; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	cache.SetMetrics(nil, "")
+	cache.Add("moo", "value5")
+	if e, a := float64(1), provider.values["cache_size{test}"]; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestCache_MaxWeight(t *testing.T) {
+	cases := []struct {
+		maxWeight      int64
+		values         []cacheEntity
+		expectedKeys   []string
+		expectedWeight int64
+	}{
+		{
+			maxWeight:      10,
+			values:         []cacheEntity{{"a", "1234"}, {"b", "1234"}, {"c", "12"}},
+			expectedKeys:   []string{"a", "b", "c"},
+			expectedWeight: 10,
+		},
+		{
+			maxWeight:      10,
+			values:         []cacheEntity{{"a", "1234"}, {"b", "1234"}, {"c", "123"}},
+			expectedKeys:   []string{"b", "c"},
+			expectedWeight: 7,
+		},
+		{
+			// a heavier value replaces a lighter one
+			maxWeight:      10,
+			values:         []cacheEntity{{"a", "1234"}, {"b", "1234"}, {"a", "12345678"}},
+			expectedKeys:   []string{"a"},
+			expectedWeight: 8,
+		},
+		{
+			// a value heavier than the max weight is not kept
+			maxWeight:      10,
+			values:         []cacheEntity{{"a", "1234"}, {"b", "12345678901"}},
+			expectedKeys:   []string{},
+			expectedWeight: 0,
+		},
+	}
+
+	for i, c := range cases {
+		cache := New[string, string](100, WithMaxWeight(c.maxWeight))
+		cache.SetWeigher(func(key string, value string) int64 {
+			return int64(len(value))
+		})
+		for _, value := range c.values {
+			cache.Add(value.Key, value.Value)
+		}
+
+		keys := cache.Keys()
+		sort.Strings(keys)
+		if e, a := c.expectedKeys, keys; !reflect.DeepEqual(e, a) {
+			t.Errorf("%d, expected %v, but received %v", i, e, a)
+		}
+		if e, a := c.expectedWeight, cache.Weight(); e != a {
+			t.Errorf("%d, expected %v, but received %v", i, e, a)
+		}
+	}
+
+	cache := New[string, string](100, WithMaxWeight(3))
+	cache.Add("a", "1234")
+	cache.Add("b", "1234")
+	cache.Add("c", "1234")
+	if e, a := int64(3), cache.Weight(); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	cache.SetWeigher(func(key string, value string) int64 {
+		return int64(len(value))
+	})
+	if e, a := int64(0), cache.Len(); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	cache.UpdateMaxWeight(0)
+	cache.Add("a", "1234")
+	if e, a := int64(4), cache.Weight(); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestCache_GetOrLoad(t *testing.T) {
+	cache := New[string, string](10)
+
+	var calls int64
+	release := make(chan struct{})
+	loader := func(ctx context.Context, key string) (string, error) {
+		atomic.AddInt64(&calls, 1)
+		<-release
+		return key + "-value", nil
+	}
+
+	var wg sync.WaitGroup
+	values := make([]string, 10)
+	for i := range values {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			value, err := cache.GetOrLoad(context.Background(), "foo", loader)
+			if err != nil {
+				t.Errorf("expected nil, but received %v", err)
+			}
+			values[i] = value
+		}(i)
+	}
+	time.Sleep(time.Millisecond * 10)
+	close(release)
+	wg.Wait()
+
+	if e, a := int64(1), atomic.LoadInt64(&calls); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	for _, value := range values {
+		if e, a := "foo-value", value; e != a {
+			t.Errorf("expected %v, but received %v", e, a)
+		}
+	}
+	if value, found := cache.Get("foo"); !found || value != "foo-value" {
+		t.Errorf("expected %v, but received %v", "foo-value", value)
+	}
+
+	loadError := errors.New("load error")
+	_, err := cache.GetOrLoad(context.Background(), "bar", func(ctx context.Context, key string) (string, error) {
+		return "", loadError
+	})
+	if e, a := loadError, err; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if _, found := cache.Get("bar"); found {
+		t.Errorf("expected %v, but received %v", false, found)
+	}
+
+	stats := cache.Stats()
+	if e, a := int64(1), stats.Loads; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := int64(1), stats.LoadErrors; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if stats.AverageLoadTime <= 0 {
+		t.Errorf("expected positive, but received %v", stats.AverageLoadTime)
+	}
+}
+
+func TestCache_GetOrLoadContext(t *testing.T) {
+	cache := New[string, string](10)
+
+	release := make(chan struct{})
+	defer close(release)
+	go cache.GetOrLoad(context.Background(), "foo", func(ctx context.Context, key string) (string, error) {
+		<-release
+		return "value", nil
+	})
+	time.Sleep(time.Millisecond * 10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	_, err := cache.GetOrLoad(ctx, "foo", func(ctx context.Context, key string) (string, error) {
+		t.Errorf("expected the in-flight loader to be shared")
+		return "", nil
+	})
+	if e, a := context.DeadlineExceeded, err; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestCache_GetOrLoadLeaderCancel(t *testing.T) {
+	cache := New[string, string](10)
+
+	release := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error)
+	go func() {
+		_, err := cache.GetOrLoad(ctx, "foo", func(ctx context.Context, key string) (string, error) {
+			<-release
+			return "value", ctx.Err()
+		})
+		leaderErr <- err
+	}()
+	time.Sleep(time.Millisecond * 10)
+
+	waiterValue := make(chan string)
+	go func() {
+		value, err := cache.GetOrLoad(context.Background(), "foo", func(ctx context.Context, key string) (string, error) {
+			t.Errorf("expected the in-flight loader to be shared")
+			return "", nil
+		})
+		if err != nil {
+			t.Errorf("expected nil, but received %v", err)
+		}
+		waiterValue <- value
+	}()
+	time.Sleep(time.Millisecond * 10)
+
+	// the goroutine that started the load returns, the load goes on for the others
+	cancel()
+	if e, a := context.Canceled, <-leaderErr; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	close(release)
+	if e, a := "value", <-waiterValue; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestCache_GetOrLoadPanic(t *testing.T) {
+	cache := New[string, string](10)
+
+	panicErr := errors.New("boom")
+	_, err := cache.GetOrLoad(context.Background(), "foo", func(ctx context.Context, key string) (string, error) {
+		panic(panicErr)
+	})
+	if !errors.Is(err, panicErr) {
+		t.Errorf("expected %v, but received %v", panicErr, err)
+	}
+	if _, found := cache.Get("foo"); found {
+		t.Errorf("expected %v, but received %v", false, found)
+	}
+	if e, a := int64(1), cache.Stats().LoadErrors; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	_, err = cache.GetOrLoad(context.Background(), "bar", func(ctx context.Context, key string) (string, error) {
+		panic("boom")
+	})
+	if err == nil {
+		t.Errorf("expected error, but received %v", err)
+	}
+}
+
+func TestCache_RefreshAhead(t *testing.T) {
+	clk := fakeclock.New(time.Now())
+	cache := New[string, int](10, WithDefaultTTL(time.Millisecond*100), WithRefreshAhead(0.5), WithClock(clk))
+
+	var calls int64
+	loader := func(ctx context.Context, key string) (int, error) {
+		return int(atomic.AddInt64(&calls, 1)), nil
+	}
+
+	value, _ := cache.GetOrLoad(context.Background(), "foo", loader)
+	if e, a := 1, value; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	// before the refresh ahead point the cached value is returned without load
+	value, _ = cache.GetOrLoad(context.Background(), "foo", loader)
+	if e, a := 1, value; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	// after it the stale value is returned and reloaded in the background
+	clk.Advance(time.Millisecond * 50)
+	value, _ = cache.GetOrLoad(context.Background(), "foo", loader)
+	if e, a := 1, value; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	time.Sleep(time.Millisecond * 20)
+	value, found := cache.Get("foo")
+	if !found {
+		t.Errorf("expected %v, but received %v", true, found)
+	}
+	if e, a := 2, value; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestCache_Shards(t *testing.T) {
+	cache := New[int, int](100, WithShards(8))
+	for i := 0; i < 1000; i++ {
+		cache.Add(i, i)
+	}
+
+	// every shard keeps its part of the limit
+	if e, a := int64(100), cache.Len(); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	for i, s := range cache.shards {
+		if e, a := splitLimit(100, i, 8), int64(len(s.entries)); e != a {
+			t.Errorf("%d, expected %v, but received %v", i, e, a)
+		}
+	}
+	for _, key := range cache.Keys() {
+		if value, found := cache.Get(key); !found || value != key {
+			t.Errorf("expected %v, but received %v", key, value)
+		}
+	}
+
+	cache.UpdateCacheLimit(10)
+	if e, a := int64(10), cache.Len(); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := int64(10), cache.CacheLimit(); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+// checkConsistency verifies that the counters, the entries and the policies
+// of a cache which is not being used agree with each other.
+func checkConsistency[K comparable, V any](t *testing.T, name string, cache *Cache[K, V]) {
+	var entries, weight int64
+	for i, s := range cache.shards {
+		s.lock.Lock()
+		var shardWeight int64
+		for _, e := range s.entries {
+			shardWeight += e.weight
+		}
+		if e, a := len(s.entries), s.policy.len(); e != a {
+			t.Errorf("%s, shard %d, expected %v keys in the policy, but received %v", name, i, e, a)
+		}
+		if e, a := shardWeight, s.weight; e != a {
+			t.Errorf("%s, shard %d, expected weight %v, but received %v", name, i, e, a)
+		}
+		if a, limit := int64(len(s.entries)), s.cacheLimit; a > limit {
+			t.Errorf("%s, shard %d, expected at most %v entries, but received %v", name, i, limit, a)
+		}
+		entries += int64(len(s.entries))
+		weight += shardWeight
+		s.lock.Unlock()
+	}
+
+	if e, a := entries, cache.Len(); e != a {
+		t.Errorf("%s, expected size %v, but received %v", name, e, a)
+	}
+	if e, a := weight, cache.Weight(); e != a {
+		t.Errorf("%s, expected weight %v, but received %v", name, e, a)
+	}
+	if limit, a := cache.CacheLimit(), cache.Len(); a > limit {
+		t.Errorf("%s, expected at most %v entries, but received %v", name, limit, a)
+	}
+
+	stats := cache.Stats()
+	removed := stats.Evictions[ReasonCapacity] + stats.Evictions[ReasonExpired] + stats.Evictions[ReasonDeleted]
+	if e, a := stats.Adds-removed, stats.Size; e != a {
+		t.Errorf("%s, expected adds - evictions %v, but received size %v", name, e, a)
+	}
+}
+
+func TestCache_Stress(t *testing.T) {
+	const goroutines = 16
+	const operations = 2000
+
+	for _, evictionPolicy := range []EvictionPolicy{LRU, LFU, TinyLFU, FIFO} {
+		for _, shards := range []int{1, 4} {
+			name := fmt.Sprintf("%v/shards%d", evictionPolicy, shards)
+			cache := New[int, int](32, WithEvictionPolicy(evictionPolicy), WithShards(shards))
+
+			var evicted int64
+			cache.OnEvict(func(key int, value int, reason EvictionReason) {
+				if reason != ReasonReplaced {
+					atomic.AddInt64(&evicted, 1)
+				}
+			})
+
+			var wg sync.WaitGroup
+			for g := 0; g < goroutines; g++ {
+				wg.Add(1)
+				go func(g int) {
+					defer wg.Done()
+					for i := 0; i < operations; i++ {
+						key := (g*operations + i*7) % 100
+						switch i % 10 {
+						case 0:
+							cache.Delete(key)
+						case 1:
+							cache.UpdateCacheLimit(int64(i%64 + 1))
+						case 2:
+							cache.AddWithTTL(key, i, time.Microsecond)
+						case 3:
+							cache.DeleteExpired()
+						case 4, 5, 6:
+							cache.Get(key)
+						default:
+							cache.Add(key, i)
+						}
+						if a := cache.Len(); a < 0 {
+							t.Errorf("%s, expected a positive size, but received %v", name, a)
+						}
+					}
+				}(g)
+			}
+			wg.Wait()
+
+			checkConsistency(t, name, cache)
+			if e, a := cache.Stats().Adds-atomic.LoadInt64(&evicted), cache.Len(); e != a {
+				t.Errorf("%s, expected adds - evicted %v, but received %v", name, e, a)
+			}
+
+			cache.UpdateCacheLimit(2)
+			checkConsistency(t, name, cache)
+		}
+	}
+}
+
+// syncMapCache is the sync.Map based cache that Cache replaced, without its
+// eviction, as a baseline of the benchmarks.
+type syncMapCache struct {
+	m sync.Map
+}
+
+func (c *syncMapCache) Get(key int) (int, bool) {
+	value, found := c.m.Load(key)
+	if !found {
+		return 0, false
+	}
+	return value.(int), true
+}
+
+func (c *syncMapCache) Add(key int, value int) {
+	c.m.Store(key, value)
+}
+
+type benchmarkCache interface {
+	Get(key int) (int, bool)
+	Add(key int, value int)
+}
+
+const benchmarkKeys = 1024
+
+// benchmarkCacheLimit leaves room for the uneven spread of the keys between
+// the shards, so that the benchmarks do not evict
+const benchmarkCacheLimit = benchmarkKeys * 4
+
+func benchmarkReadWrite(b *testing.B, cache benchmarkCache, readPercent int) {
+	for i := 0; i < benchmarkKeys; i++ {
+		cache.Add(i, i)
+	}
+
+	var seq uint64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		// each goroutine walks the keys from a different offset
+		i := int(atomic.AddUint64(&seq, 7919))
+		for pb.Next() {
+			i++
+			key := i % benchmarkKeys
+			if i%100 < readPercent {
+				cache.Get(key)
+			} else {
+				cache.Add(key, i)
+			}
+		}
+	})
+}
+
+func BenchmarkCache(b *testing.B) {
+	for _, readPercent := range []int{100, 90, 50, 10} {
+		b.Run(fmt.Sprintf("read%d/syncMap", readPercent), func(b *testing.B) {
+			benchmarkReadWrite(b, &syncMapCache{}, readPercent)
+		})
+		b.Run(fmt.Sprintf("read%d/singleLock", readPercent), func(b *testing.B) {
+			benchmarkReadWrite(b, New[int, int](benchmarkCacheLimit), readPercent)
+		})
+		b.Run(fmt.Sprintf("read%d/shards16", readPercent), func(b *testing.B) {
+			benchmarkReadWrite(b, New[int, int](benchmarkCacheLimit, WithShards(16)), readPercent)
+		})
+		b.Run(fmt.Sprintf("read%d/defaultShards", readPercent), func(b *testing.B) {
+			benchmarkReadWrite(b, New[int, int](benchmarkCacheLimit, WithShards(DefaultShards(benchmarkCacheLimit))), readPercent)
+		})
+	}
+}
+
+func TestCache_DefaultShards(t *testing.T) {
+	procs := runtime.GOMAXPROCS(0)
+	tests := []struct {
+		cacheLimit int64
+		expected   int
+	}{
+		{cacheLimit: 0, expected: 1},
+		{cacheLimit: 15, expected: 1},
+		{cacheLimit: 32, expected: minInt(2, procs)},
+		{cacheLimit: int64(procs) * 1000, expected: procs},
+	}
+	for _, tt := range tests {
+		if e, a := tt.expected, DefaultShards(tt.cacheLimit); e != a {
+			t.Errorf("expected %v, but received %v", e, a)
+		}
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func TestCache_Snapshot(t *testing.T) {
+	clk := fakeclock.New(time.Now())
+	cache := New[string, cacheEntity](10, WithShards(2), WithClock(clk))
+	cache.Add("foo", cacheEntity{Key: "foo", Value: "value0"})
+	cache.AddWithTTL("bar", cacheEntity{Key: "bar", Value: "value1"}, time.Hour)
+	cache.AddWithTTL("baz", cacheEntity{Key: "baz", Value: "value2"}, time.Millisecond*20)
+	_, fooMeta, _ := cache.GetWithMeta("foo")
+	_, barMeta, _ := cache.GetWithMeta("bar")
+
+	var buf bytes.Buffer
+	if err := cache.Snapshot(&buf); err != nil {
+		t.Fatalf("expected nil, but received %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if e, a := 4, len(lines); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := `{"format":"aws-sdk-enhanced-go/cache","version":1,"keyType":"string","valueType":"cache.cacheEntity"`, lines[0]; !strings.HasPrefix(a, e) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	clk.Advance(time.Millisecond * 20)
+	restored := New[string, cacheEntity](10, WithClock(clk))
+	if err := restored.Restore(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("expected nil, but received %v", err)
+	}
+
+	keys := restored.Keys()
+	sort.Strings(keys)
+	if e, a := []string{"bar", "foo"}, keys; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	for key, expectedMeta := range map[string]Meta{"foo": fooMeta, "bar": barMeta} {
+		value, meta, _ := restored.GetWithMeta(key)
+		if e, a := key, value.Key; e != a {
+			t.Errorf("expected %v, but received %v", e, a)
+		}
+		if !meta.AddedAt.Equal(expectedMeta.AddedAt) || !meta.ExpiresAt.Equal(expectedMeta.ExpiresAt) {
+			t.Errorf("expected %v, but received %v", expectedMeta, meta)
+		}
+	}
+
+	cases := []struct {
+		name     string
+		snapshot string
+	}{
+		{name: "wrong types", snapshot: buf.String()},
+		{name: "unknown format", snapshot: `{"format":"other","version":1}`},
+		{name: "unsupported version", snapshot: `{"format":"aws-sdk-enhanced-go/cache","version":2}`},
+	}
+	for _, c := range cases {
+		if err := New[int, string](10).Restore(strings.NewReader(c.snapshot)); err == nil {
+			t.Errorf("%s, expected an error, but received nil", c.name)
+		}
+	}
+
+	// the invalid entries are skipped, the valid ones after them are restored
+	skipped := New[int, string](10)
+	snapshot := `{"format":"aws-sdk-enhanced-go/cache","version":1,"keyType":"int","valueType":"string"}` + "\n" +
+		`{"key":"a","value":"foo"}` + "\n" +
+		`{"key":2,"value":` + "\n" +
+		`{"key":3,"value":"baz"}` + "\n"
+	if err := skipped.Restore(strings.NewReader(snapshot)); err != nil {
+		t.Fatalf("expected nil, but received %v", err)
+	}
+	if e, a := []int{3}, skipped.Keys(); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestCache_SnapshotCodec(t *testing.T) {
+	cache := New[int, string](10)
+	cache.SetCodec(func(key int, value string) ([]byte, error) {
+		return json.Marshal(strings.ToUpper(value))
+	}, func(key int, data []byte) (string, error) {
+		var value string
+		err := json.Unmarshal(data, &value)
+		return fmt.Sprint(strings.ToLower(value), key), err
+	})
+	cache.Add(1, "foo")
+
+	var buf bytes.Buffer
+	if err := cache.Snapshot(&buf); err != nil {
+		t.Fatalf("expected nil, but received %v", err)
+	}
+	if !strings.Contains(buf.String(), `"value":"FOO"`) {
+		t.Errorf("expected the encoded value, but received %v", buf.String())
+	}
+
+	if err := cache.Restore(&buf); err != nil {
+		t.Fatalf("expected nil, but received %v", err)
+	}
+	if value, _ := cache.Get(1); value != "foo1" {
+		t.Errorf("expected %v, but received %v", "foo1", value)
+	}
+
+	cache.SetCodec(func(key int, value string) ([]byte, error) {
+		return []byte(value), nil
+	}, nil)
+	if err := cache.Snapshot(&buf); err == nil {
+		t.Errorf("expected an error, but received nil")
+	}
+}
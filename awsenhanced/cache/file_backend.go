@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/clock"
+)
+
+// fileHeaderSize is the size of the expiry time written before the value,
+// in unix nanoseconds, 0 means never expire.
+const fileHeaderSize = 8
+
+// FileBackend stores every key in a file of a directory, which may be shared
+// by several processes. A file is replaced atomically by a rename, so the
+// readers never see a partially written value.
+type FileBackend struct {
+	dir   string
+	clock clock.Clock
+}
+
+type FileOption func(*FileBackend)
+
+// WithFileClock replaces the clock of the time package used for the ttls,
+// e.g. by a fake clock in tests.
+func WithFileClock(c clock.Clock) FileOption {
+	return func(b *FileBackend) {
+		b.clock = c
+	}
+}
+
+// NewFileBackend creates the directory if it does not exist.
+func NewFileBackend(dir string, opts ...FileOption) (*FileBackend, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("create cache directory [%s] failed, %w", dir, err)
+	}
+
+	b := &FileBackend{
+		dir:   dir,
+		clock: clock.New(),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b, nil
+}
+
+func (b *FileBackend) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(b.dir, hex.EncodeToString(sum[:]))
+}
+
+func (b *FileBackend) Get(_ context.Context, key string) ([]byte, bool, error) {
+	data, err := os.ReadFile(b.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if len(data) < fileHeaderSize {
+		return nil, false, fmt.Errorf("invalid cache file of key [%s]", key)
+	}
+
+	expiresAt := int64(binary.BigEndian.Uint64(data[:fileHeaderSize]))
+	if expiresAt != 0 && b.clock.Now().UnixNano() >= expiresAt {
+		// expired, a failed remove does not change the result
+		_ = os.Remove(b.path(key))
+		return nil, false, nil
+	}
+	return data[fileHeaderSize:], true, nil
+}
+
+func (b *FileBackend) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = b.clock.Now().Add(ttl).UnixNano()
+	}
+
+	file, err := os.CreateTemp(b.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// the temp file no longer exists once it has been renamed
+		_ = os.Remove(file.Name())
+	}()
+
+	var header [fileHeaderSize]byte
+	binary.BigEndian.PutUint64(header[:], uint64(expiresAt))
+	_, err = file.Write(header[:])
+	if err == nil {
+		_, err = file.Write(value)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), b.path(key))
+}
+
+func (b *FileBackend) Delete(_ context.Context, key string) error {
+	err := os.Remove(b.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
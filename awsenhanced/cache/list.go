@@ -0,0 +1,59 @@
+package cache
+
+type node[K comparable] struct {
+	key        K
+	prev, next *node[K]
+}
+
+// linkedList is a doubly linked list of keys with a sentinel root node.
+type linkedList[K comparable] struct {
+	root node[K]
+}
+
+func newLinkedList[K comparable]() *linkedList[K] {
+	l := &linkedList[K]{}
+	l.root.prev = &l.root
+	l.root.next = &l.root
+	return l
+}
+
+func (l *linkedList[K]) pushFront(key K) *node[K] {
+	n := &node[K]{key: key}
+	l.insertAfter(n, &l.root)
+	return n
+}
+
+func (l *linkedList[K]) moveToFront(n *node[K]) {
+	if l.root.next == n {
+		return
+	}
+	l.remove(n)
+	l.insertAfter(n, &l.root)
+}
+
+func (l *linkedList[K]) remove(n *node[K]) {
+	n.prev.next = n.next
+	n.next.prev = n.prev
+	n.prev = nil
+	n.next = nil
+}
+
+// back returns the last node or nil if the list is empty.
+func (l *linkedList[K]) back() *node[K] {
+	return l.prev(&l.root)
+}
+
+// prev returns the previous node or nil if n is the first one.
+func (l *linkedList[K]) prev(n *node[K]) *node[K] {
+	if n.prev == &l.root {
+		return nil
+	}
+	return n.prev
+}
+
+func (l *linkedList[K]) insertAfter(n, at *node[K]) {
+	n.prev = at
+	n.next = at.next
+	at.next.prev = n
+	at.next = n
+}
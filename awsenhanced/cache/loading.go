@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/logger"
+)
+
+// Loader loads the value of a key missing from the cache.
+type Loader[K comparable, V any] func(ctx context.Context, key K) (V, error)
+
+// call is an in-flight or completed Loader call.
+type call[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// GetOrLoad returns the cached value, or calls loader once per key for all the
+// goroutines missing it. The load is bounded by the load timeout instead of
+// the caller's ctx, and a panic of the loader is returned as an error.
+func (c *Cache[K, V]) GetOrLoad(ctx context.Context, key K, loader Loader[K, V]) (V, error) {
+	value, meta, found := c.GetWithMeta(key)
+	if found {
+		if c.shouldRefreshAhead(meta) {
+			c.refreshAhead(key, loader)
+		}
+		return value, nil
+	}
+
+	cl, isLeader := c.startCall(key)
+	if isLeader {
+		go c.load(detach(ctx), key, loader, cl)
+	}
+
+	select {
+	case <-cl.done:
+		return cl.value, cl.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// startCall returns the in-flight call of the key, or a new one whose loader
+// must be called by the caller.
+func (c *Cache[K, V]) startCall(key K) (*call[V], bool) {
+	c.callsLock.Lock()
+	defer c.callsLock.Unlock()
+
+	if cl, found := c.calls[key]; found {
+		return cl, false
+	}
+
+	if c.calls == nil {
+		c.calls = map[K]*call[V]{}
+	}
+	cl := &call[V]{done: make(chan struct{})}
+	c.calls[key] = cl
+	return cl, true
+}
+
+func (c *Cache[K, V]) load(ctx context.Context, key K, loader Loader[K, V], cl *call[V]) {
+	defer func() {
+		c.callsLock.Lock()
+		delete(c.calls, key)
+		c.callsLock.Unlock()
+		close(cl.done)
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, c.loadTimeout)
+	defer cancel()
+
+	startTime := c.clock.Now()
+	cl.value, cl.err = callLoader(ctx, key, loader)
+	atomic.AddInt64(&c.counters.loadNanos, int64(c.clock.Since(startTime)))
+
+	if cl.err != nil {
+		atomic.AddInt64(&c.counters.loadErrors, 1)
+		return
+	}
+	atomic.AddInt64(&c.counters.loads, 1)
+	c.Add(key, cl.value)
+}
+
+// callLoader calls loader and turns its panic into an error.
+func callLoader[K comparable, V any](ctx context.Context, key K, loader Loader[K, V]) (value V, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			var zero V
+			value = zero
+			if panicErr, ok := r.(error); ok {
+				err = fmt.Errorf("loader of key [%v] panic, %w", key, panicErr)
+			} else {
+				err = fmt.Errorf("loader of key [%v] panic, %v", key, r)
+			}
+		}
+	}()
+
+	return loader(ctx, key)
+}
+
+// detachedContext keeps the values of its parent without its deadline and
+// cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func detach(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (d detachedContext) Value(key interface{}) interface{} {
+	return d.parent.Value(key)
+}
+
+func (c *Cache[K, V]) shouldRefreshAhead(meta Meta) bool {
+	if c.refreshAheadFraction <= 0 || meta.ExpiresAt.IsZero() {
+		return false
+	}
+	ttl := meta.ExpiresAt.Sub(meta.AddedAt)
+	refreshAt := meta.AddedAt.Add(time.Duration(float64(ttl) * c.refreshAheadFraction))
+	return !c.clock.Now().Before(refreshAt)
+}
+
+// refreshAhead reloads the key in the background unless it is being loaded.
+func (c *Cache[K, V]) refreshAhead(key K, loader Loader[K, V]) {
+	cl, isLeader := c.startCall(key)
+	if !isLeader {
+		return
+	}
+
+	go func() {
+		c.load(context.Background(), key, loader, cl)
+		if cl.err != nil {
+			c.logger.Get().Warn("refresh ahead of the key fail", logger.Any("key", key), logger.Err(cl.err))
+		}
+	}()
+}
+
+// inFlight holds the Loader calls of GetOrLoad, callsLock is separated from
+// the cache lock because the loaders are called without holding any lock.
+type inFlight[K comparable, V any] struct {
+	callsLock sync.Mutex
+	calls     map[K]*call[V]
+}
@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/metrics"
+)
+
+// cacheMetrics are the instruments of a cache, labelled with its name.
+type cacheMetrics struct {
+	name      string
+	size      metrics.Gauge
+	evictions metrics.Counter
+}
+
+func newCacheMetrics(provider metrics.Provider, name string) *cacheMetrics {
+	if provider == nil {
+		return nil
+	}
+	return &cacheMetrics{
+		name:      name,
+		size:      provider.Gauge("cache_size", "The number of entries in the cache.", "cache"),
+		evictions: provider.Counter("cache_evictions_total", "The number of entries removed from the cache.", "cache", "reason"),
+	}
+}
+
+// SetMetrics records the size and the evictions of the cache to provider,
+// labelled with name; nil stops recording.
+func (c *Cache[K, V]) SetMetrics(provider metrics.Provider, name string) {
+	c.metrics.Store(newCacheMetrics(provider, name))
+	c.recordMetrics(nil)
+}
+
+// recordMetrics is called with the evictions of every change, the replaced
+// values are not counted as they are still in the cache.
+func (c *Cache[K, V]) recordMetrics(evictions []eviction[K, V]) {
+	m, _ := c.metrics.Load().(*cacheMetrics)
+	if m == nil {
+		return
+	}
+
+	m.size.Set(float64(c.Len()), m.name)
+	for _, e := range evictions {
+		if e.reason != ReasonReplaced {
+			m.evictions.Add(1, m.name, e.reason.String())
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package cache
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/clock"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/logger"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/metrics"
+)
+
+// EvictionPolicy chooses the key to evict when the cache exceeds its limit.
+type EvictionPolicy int
+
+const (
+	// LRU evicts the least recently read or written key.
+	LRU EvictionPolicy = iota
+	// LFU evicts the least frequently read or written key, the oldest one first
+	// among the keys with the same frequency.
+	LFU
+	// TinyLFU evicts like LRU, but only admits a new key used more often than
+	// the victim, as estimated by a count-min sketch.
+	TinyLFU
+	// FIFO evicts the oldest key.
+	FIFO
+)
+
+const (
+	defaultEvictionPolicy = LRU
+	defaultLoadTimeout    = time.Minute
+)
+
+func (p EvictionPolicy) String() string {
+	switch p {
+	case LRU:
+		return "LRU"
+	case LFU:
+		return "LFU"
+	case TinyLFU:
+		return "TinyLFU"
+	case FIFO:
+		return "FIFO"
+	}
+	return "Unknown"
+}
+
+type Option func(*options)
+
+type options struct {
+	evictionPolicy  EvictionPolicy
+	defaultTTL      time.Duration
+	janitorInterval time.Duration
+
+	refreshAheadFraction float64
+	loadTimeout          time.Duration
+
+	maxWeight int64
+
+	shards int
+
+	clock  clock.Clock
+	logger logger.Logger
+
+	metrics     metrics.Provider
+	metricsName string
+}
+
+func WithEvictionPolicy(evictionPolicy EvictionPolicy) Option {
+	return func(o *options) {
+		o.evictionPolicy = evictionPolicy
+	}
+}
+
+// WithDefaultTTL sets the ttl of the entries added by Add, 0 means never expire.
+func WithDefaultTTL(defaultTTL time.Duration) Option {
+	return func(o *options) {
+		o.defaultTTL = defaultTTL
+	}
+}
+
+// WithJanitor starts a goroutine deleting the expired entries every interval,
+// until Close is called. Without it the expired entries are only deleted
+// when they are read.
+func WithJanitor(interval time.Duration) Option {
+	return func(o *options) {
+		o.janitorInterval = interval
+	}
+}
+
+// WithRefreshAhead makes GetOrLoad reload a value in the background when it is
+// read after the given fraction, between 0 and 1, of its ttl, so that the hot
+// keys do not expire.
+func WithRefreshAhead(fraction float64) Option {
+	return func(o *options) {
+		o.refreshAheadFraction = fraction
+	}
+}
+
+// WithLoadTimeout bounds the Loader calls of GetOrLoad, which are not
+// cancelled with the ctx of their caller. It is 1 minute by default.
+func WithLoadTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.loadTimeout = timeout
+	}
+}
+
+// WithMaxWeight limits the total weight of the values, every value weighs 1
+// unless a weigher is set by SetWeigher. The entry limit still applies.
+func WithMaxWeight(maxWeight int64) Option {
+	return func(o *options) {
+		o.maxWeight = maxWeight
+	}
+}
+
+// WithShards splits the cache and its limits into n shards with their own
+// lock, the eviction policy is then per shard. 1 by default.
+func WithShards(n int) Option {
+	return func(o *options) {
+		o.shards = n
+	}
+}
+
+// minShardEntries is the fewest entries DefaultShards leaves to a shard, so
+// that the eviction policy of each shard stays close to the one of the cache.
+const minShardEntries = 16
+
+// DefaultShards returns a number of shards for a cache of cacheLimit entries
+// read by all the processors: runtime.GOMAXPROCS, but no more than one shard
+// per 16 entries.
+func DefaultShards(cacheLimit int64) int {
+	shards := runtime.GOMAXPROCS(0)
+	if limit := cacheLimit / minShardEntries; int64(shards) > limit {
+		shards = int(limit)
+	}
+	if shards < 1 {
+		shards = 1
+	}
+	return shards
+}
+
+// WithClock replaces the clock of the time package used for the ttls, e.g. by
+// a fake clock in tests.
+func WithClock(c clock.Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+// WithLogger sets the logger of the cache instead of the default logger.
+func WithLogger(l logger.Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// WithMetrics records the size and the evictions of the cache to provider,
+// labelled with name so that several caches can share a provider.
+func WithMetrics(provider metrics.Provider, name string) Option {
+	return func(o *options) {
+		o.metrics = provider
+		o.metricsName = name
+	}
+}
@@ -0,0 +1,236 @@
+package cache
+
+import (
+	"container/heap"
+	"hash/maphash"
+)
+
+// policy tracks the keys of a Cache to choose the one to evict,
+// it is not safe for concurrent use.
+type policy[K comparable] interface {
+	add(key K)
+	access(key K)
+	remove(key K)
+	// victim returns the key to evict. candidate is the key that has just been
+	// added or nil, only an admission policy may return it.
+	victim(candidate *K) (K, bool)
+	resize(cacheLimit int64)
+	// len returns the number of the tracked keys, which is the number of the
+	// entries of the shard
+	len() int
+}
+
+func newPolicy[K comparable](evictionPolicy EvictionPolicy, cacheLimit int64) policy[K] {
+	switch evictionPolicy {
+	case LFU:
+		return newLFUPolicy[K]()
+	case TinyLFU:
+		return newTinyLFUPolicy[K](cacheLimit)
+	case FIFO:
+		return newListPolicy[K](false)
+	default:
+		return newListPolicy[K](true)
+	}
+}
+
+// listPolicy is LRU when moveOnAccess, otherwise FIFO.
+type listPolicy[K comparable] struct {
+	moveOnAccess bool
+	list         *linkedList[K]
+	nodes        map[K]*node[K]
+}
+
+func newListPolicy[K comparable](moveOnAccess bool) *listPolicy[K] {
+	return &listPolicy[K]{
+		moveOnAccess: moveOnAccess,
+		list:         newLinkedList[K](),
+		nodes:        map[K]*node[K]{},
+	}
+}
+
+func (p *listPolicy[K]) add(key K) {
+	p.nodes[key] = p.list.pushFront(key)
+}
+
+func (p *listPolicy[K]) access(key K) {
+	if !p.moveOnAccess {
+		return
+	}
+	if n, found := p.nodes[key]; found {
+		p.list.moveToFront(n)
+	}
+}
+
+func (p *listPolicy[K]) remove(key K) {
+	if n, found := p.nodes[key]; found {
+		p.list.remove(n)
+		delete(p.nodes, key)
+	}
+}
+
+func (p *listPolicy[K]) victim(candidate *K) (K, bool) {
+	for n := p.list.back(); n != nil; n = p.list.prev(n) {
+		if candidate == nil || n.key != *candidate {
+			return n.key, true
+		}
+	}
+	var zero K
+	return zero, false
+}
+
+func (p *listPolicy[K]) resize(int64) {}
+
+func (p *listPolicy[K]) len() int {
+	return len(p.nodes)
+}
+
+type lfuItem[K comparable] struct {
+	key       K
+	frequency uint64
+	// sequence orders the items with the same frequency, the oldest first
+	sequence uint64
+	index    int
+}
+
+type lfuHeap[K comparable] []*lfuItem[K]
+
+func (h lfuHeap[K]) Len() int { return len(h) }
+
+func (h lfuHeap[K]) Less(i, j int) bool {
+	if h[i].frequency != h[j].frequency {
+		return h[i].frequency < h[j].frequency
+	}
+	return h[i].sequence < h[j].sequence
+}
+
+func (h lfuHeap[K]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap[K]) Push(x interface{}) {
+	item := x.(*lfuItem[K])
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *lfuHeap[K]) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
+
+type lfuPolicy[K comparable] struct {
+	heap     lfuHeap[K]
+	items    map[K]*lfuItem[K]
+	sequence uint64
+}
+
+func newLFUPolicy[K comparable]() *lfuPolicy[K] {
+	return &lfuPolicy[K]{
+		items: map[K]*lfuItem[K]{},
+	}
+}
+
+func (p *lfuPolicy[K]) add(key K) {
+	p.sequence++
+	item := &lfuItem[K]{key: key, frequency: 1, sequence: p.sequence}
+	p.items[key] = item
+	heap.Push(&p.heap, item)
+}
+
+func (p *lfuPolicy[K]) access(key K) {
+	if item, found := p.items[key]; found {
+		item.frequency++
+		heap.Fix(&p.heap, item.index)
+	}
+}
+
+func (p *lfuPolicy[K]) remove(key K) {
+	if item, found := p.items[key]; found {
+		heap.Remove(&p.heap, item.index)
+		delete(p.items, key)
+	}
+}
+
+func (p *lfuPolicy[K]) victim(candidate *K) (K, bool) {
+	var zero K
+	if len(p.heap) == 0 {
+		return zero, false
+	}
+	if candidate == nil || p.heap[0].key != *candidate {
+		return p.heap[0].key, true
+	}
+
+	// the second least item is one of the children of the root
+	var second *lfuItem[K]
+	for i := 1; i <= 2 && i < len(p.heap); i++ {
+		if second == nil || p.heap.Less(i, second.index) {
+			second = p.heap[i]
+		}
+	}
+	if second == nil {
+		return zero, false
+	}
+	return second.key, true
+}
+
+func (p *lfuPolicy[K]) resize(int64) {}
+
+func (p *lfuPolicy[K]) len() int {
+	return len(p.items)
+}
+
+// tinyLFUPolicy admits a new key only if it is estimated to be used more
+// frequently than the LRU victim.
+type tinyLFUPolicy[K comparable] struct {
+	lru    *listPolicy[K]
+	sketch *countMinSketch
+	seed   maphash.Seed
+}
+
+func newTinyLFUPolicy[K comparable](cacheLimit int64) *tinyLFUPolicy[K] {
+	return &tinyLFUPolicy[K]{
+		lru:    newListPolicy[K](true),
+		sketch: newCountMinSketch(cacheLimit),
+		seed:   maphash.MakeSeed(),
+	}
+}
+
+func (p *tinyLFUPolicy[K]) add(key K) {
+	p.sketch.increment(hashKey(p.seed, key))
+	p.lru.add(key)
+}
+
+func (p *tinyLFUPolicy[K]) access(key K) {
+	p.sketch.increment(hashKey(p.seed, key))
+	p.lru.access(key)
+}
+
+func (p *tinyLFUPolicy[K]) remove(key K) {
+	p.lru.remove(key)
+}
+
+func (p *tinyLFUPolicy[K]) victim(candidate *K) (K, bool) {
+	key, found := p.lru.victim(candidate)
+	if !found || candidate == nil {
+		return key, found
+	}
+
+	if p.sketch.estimate(hashKey(p.seed, *candidate)) <= p.sketch.estimate(hashKey(p.seed, key)) {
+		return *candidate, true
+	}
+	return key, true
+}
+
+func (p *tinyLFUPolicy[K]) resize(cacheLimit int64) {
+	p.sketch = newCountMinSketch(cacheLimit)
+}
+
+func (p *tinyLFUPolicy[K]) len() int {
+	return p.lru.len()
+}
@@ -0,0 +1,345 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultRedisTimeout  = time.Second * 3
+	defaultRedisMaxIdle  = 4
+	redisMaxBulkSize     = 512 * 1024 * 1024
+	redisNullBulkLength  = -1
+	redisReplyOK         = "OK"
+	redisReplyTypeString = '+'
+	redisReplyTypeError  = '-'
+	redisReplyTypeInt    = ':'
+	redisReplyTypeBulk   = '$'
+)
+
+// RedisBackend is a Backend speaking the Redis protocol (RESP) with the GET,
+// SET and DEL commands, so it works with Redis and the compatible servers.
+type RedisBackend struct {
+	address  string
+	password string
+	db       int
+	timeout  time.Duration
+
+	// idle holds the connections that can be reused
+	idle chan *redisConn
+}
+
+type RedisOption func(*RedisBackend)
+
+// WithRedisPassword sends AUTH on every new connection.
+func WithRedisPassword(password string) RedisOption {
+	return func(b *RedisBackend) {
+		b.password = password
+	}
+}
+
+// WithRedisDB sends SELECT on every new connection.
+func WithRedisDB(db int) RedisOption {
+	return func(b *RedisBackend) {
+		b.db = db
+	}
+}
+
+// WithRedisTimeout sets the timeout of dialing and of every command, unless
+// the context has an earlier deadline. Cancelling the context interrupts the
+// command too.
+func WithRedisTimeout(timeout time.Duration) RedisOption {
+	return func(b *RedisBackend) {
+		b.timeout = timeout
+	}
+}
+
+// WithRedisMaxIdle sets the number of the idle connections kept for reuse.
+func WithRedisMaxIdle(maxIdle int) RedisOption {
+	return func(b *RedisBackend) {
+		b.idle = make(chan *redisConn, maxIdle)
+	}
+}
+
+// NewRedisBackend connects lazily to the server at address, e.g. "localhost:6379".
+func NewRedisBackend(address string, opts ...RedisOption) *RedisBackend {
+	b := &RedisBackend{
+		address: address,
+		timeout: defaultRedisTimeout,
+		idle:    make(chan *redisConn, defaultRedisMaxIdle),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+// redisError is an error replied by the server, the connection can be reused.
+type redisError string
+
+func (e redisError) Error() string {
+	return fmt.Sprintf("redis error [%s]", string(e))
+}
+
+func (b *RedisBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := b.do(ctx, "GET", []byte(key))
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("unexpected reply of redis GET [%v]", reply)
+	}
+	return value, true, nil
+}
+
+func (b *RedisBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := [][]byte{[]byte(key), value}
+	if ttl > 0 {
+		args = append(args, []byte("PX"), []byte(strconv.FormatInt(ttl.Milliseconds(), 10)))
+	}
+
+	reply, err := b.do(ctx, "SET", args...)
+	if err != nil {
+		return err
+	}
+	if reply != redisReplyOK {
+		return fmt.Errorf("unexpected reply of redis SET [%v]", reply)
+	}
+	return nil
+}
+
+func (b *RedisBackend) Delete(ctx context.Context, key string) error {
+	_, err := b.do(ctx, "DEL", []byte(key))
+	return err
+}
+
+// Close closes the idle connections.
+func (b *RedisBackend) Close() error {
+	for {
+		select {
+		case c := <-b.idle:
+			_ = c.conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// do sends a command and returns the reply, which is a string, an int64, a
+// []byte or nil for the null bulk string. An idle connection may have been
+// closed by the server, GET and SET are sent again once on a new connection.
+func (b *RedisBackend) do(ctx context.Context, command string, args ...[]byte) (interface{}, error) {
+	c, reused, err := b.getConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := b.exec(ctx, c, command, args...)
+	if reused && isRedisIdempotent(command) && isRedisConnBroken(err) && ctx.Err() == nil {
+		_ = c.conn.Close()
+		c, err = b.dial(ctx)
+		if err != nil {
+			return nil, err
+		}
+		reply, err = b.exec(ctx, c, command, args...)
+	}
+
+	var replyError redisError
+	if err != nil && !errors.As(err, &replyError) {
+		// the connection may be broken
+		_ = c.conn.Close()
+		return nil, err
+	}
+
+	b.putConn(c)
+	return reply, err
+}
+
+// exec sends a command on c, cancelling ctx interrupts it.
+func (b *RedisBackend) exec(ctx context.Context, c *redisConn, command string, args ...[]byte) (interface{}, error) {
+	stop := watchRedisConn(ctx, c.conn)
+	reply, err := c.do(b.deadline(ctx), command, args...)
+	if stop() && err != nil {
+		return nil, ctx.Err()
+	}
+	return reply, err
+}
+
+// watchRedisConn makes the pending reads and writes of conn fail at once when
+// ctx is done, the returned stop reports whether it has happened.
+func watchRedisConn(ctx context.Context, conn net.Conn) (stop func() bool) {
+	if ctx.Done() == nil {
+		return func() bool { return false }
+	}
+
+	done := make(chan struct{})
+	cancelled := make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.SetDeadline(time.Unix(1, 0))
+			cancelled <- true
+		case <-done:
+			cancelled <- false
+		}
+	}()
+	return func() bool {
+		close(done)
+		return <-cancelled
+	}
+}
+
+func isRedisIdempotent(command string) bool {
+	return command == "GET" || command == "SET"
+}
+
+// isRedisConnBroken reports whether err is a failure of the connection rather
+// than an error replied by the server or a timeout.
+func isRedisConnBroken(err error) bool {
+	if err == nil {
+		return false
+	}
+	var replyError redisError
+	if errors.As(err, &replyError) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return false
+	}
+	return true
+}
+
+func (b *RedisBackend) deadline(ctx context.Context) time.Time {
+	deadline := time.Now().Add(b.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		return ctxDeadline
+	}
+	return deadline
+}
+
+// getConn returns an idle connection, reused is true, or a new one.
+func (b *RedisBackend) getConn(ctx context.Context) (c *redisConn, reused bool, err error) {
+	select {
+	case c := <-b.idle:
+		return c, true, nil
+	default:
+	}
+
+	c, err = b.dial(ctx)
+	return c, false, err
+}
+
+func (b *RedisBackend) dial(ctx context.Context) (*redisConn, error) {
+	dialer := net.Dialer{Deadline: b.deadline(ctx)}
+	conn, err := dialer.DialContext(ctx, "tcp", b.address)
+	if err != nil {
+		return nil, fmt.Errorf("connect to redis [%s] failed, %w", b.address, err)
+	}
+
+	c := &redisConn{conn: conn, reader: bufio.NewReader(conn), writer: bufio.NewWriter(conn)}
+	if b.password != "" {
+		_, err = b.exec(ctx, c, "AUTH", []byte(b.password))
+	}
+	if err == nil && b.db != 0 {
+		_, err = b.exec(ctx, c, "SELECT", []byte(strconv.Itoa(b.db)))
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (b *RedisBackend) putConn(c *redisConn) {
+	select {
+	case b.idle <- c:
+	default:
+		_ = c.conn.Close()
+	}
+}
+
+func (c *redisConn) do(deadline time.Time, command string, args ...[]byte) (interface{}, error) {
+	err := c.conn.SetDeadline(deadline)
+	if err != nil {
+		return nil, err
+	}
+
+	// a command is sent as an array of bulk strings
+	_, _ = fmt.Fprintf(c.writer, "*%d\r\n$%d\r\n%s\r\n", len(args)+1, len(command), command)
+	for _, arg := range args {
+		_, _ = fmt.Fprintf(c.writer, "$%d\r\n", len(arg))
+		_, _ = c.writer.Write(arg)
+		_, _ = c.writer.WriteString("\r\n")
+	}
+	err = c.writer.Flush()
+	if err != nil {
+		return nil, err
+	}
+
+	return readRedisReply(c.reader)
+}
+
+func readRedisReply(reader *bufio.Reader) (interface{}, error) {
+	line, err := readRedisLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("empty redis reply")
+	}
+
+	switch line[0] {
+	case redisReplyTypeString:
+		return line[1:], nil
+	case redisReplyTypeError:
+		return nil, redisError(line[1:])
+	case redisReplyTypeInt:
+		return strconv.ParseInt(line[1:], 10, 64)
+	case redisReplyTypeBulk:
+		length, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if length == redisNullBulkLength {
+			return nil, nil
+		}
+		if length < 0 || length > redisMaxBulkSize {
+			return nil, fmt.Errorf("invalid redis bulk length [%d]", length)
+		}
+		data := make([]byte, length+2)
+		_, err = io.ReadFull(reader, data)
+		if err != nil {
+			return nil, err
+		}
+		return data[:length], nil
+	}
+	return nil, fmt.Errorf("unsupported redis reply [%s]", line)
+}
+
+func readRedisLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("invalid redis reply line [%s]", line)
+	}
+	return line[:len(line)-2], nil
+}
@@ -0,0 +1,175 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/logger"
+)
+
+// shard is a part of the cache with its own lock, entries and policy. All its
+// methods must be called with lock held.
+type shard[K comparable, V any] struct {
+	cache *Cache[K, V]
+
+	lock    sync.Mutex
+	entries map[K]*entry[V]
+	// policy chooses the key to evict when the shard exceeds cacheLimit
+	policy policy[K]
+
+	cacheLimit int64
+	maxWeight  int64
+	weight     int64
+	weigher    func(key K, value V) int64
+}
+
+func newShard[K comparable, V any](c *Cache[K, V], evictionPolicy EvictionPolicy, cacheLimit int64, maxWeight int64) *shard[K, V] {
+	return &shard[K, V]{
+		cache:      c,
+		entries:    map[K]*entry[V]{},
+		policy:     newPolicy[K](evictionPolicy, cacheLimit),
+		cacheLimit: cacheLimit,
+		maxWeight:  maxWeight,
+	}
+}
+
+func (s *shard[K, V]) get(cacheKey K, now time.Time, evictions []eviction[K, V]) (*entry[V], []eviction[K, V]) {
+	e, found := s.entries[cacheKey]
+	if !found {
+		return nil, evictions
+	}
+	if e.isExpired(now) {
+		return nil, s.delete(cacheKey, ReasonExpired, evictions)
+	}
+
+	s.policy.access(cacheKey)
+	return e, evictions
+}
+
+func (s *shard[K, V]) add(cacheKey K, cacheValue V, now time.Time, expiresAt time.Time) []eviction[K, V] {
+	weight := s.weigh(cacheKey, cacheValue)
+
+	e, found := s.entries[cacheKey]
+	if found {
+		// the key exists
+		evictions := []eviction[K, V]{{key: cacheKey, value: e.value, reason: ReasonReplaced}}
+		s.addWeight(weight - e.weight)
+		e.value = cacheValue
+		e.weight = weight
+		e.addedAt = now
+		e.expiresAt = expiresAt
+		s.policy.access(cacheKey)
+		atomic.AddInt64(&s.cache.counters.replaces, 1)
+		// the new value may be heavier
+		return s.evict(&cacheKey, evictions)
+	}
+
+	// the key does not exist
+	s.entries[cacheKey] = &entry[V]{value: cacheValue, weight: weight, addedAt: now, expiresAt: expiresAt}
+	s.policy.add(cacheKey)
+	atomic.AddInt64(&s.cache.size, 1)
+	s.addWeight(weight)
+	atomic.AddInt64(&s.cache.counters.adds, 1)
+	return s.evict(&cacheKey, nil)
+}
+
+func (s *shard[K, V]) weigh(key K, value V) int64 {
+	if s.weigher == nil {
+		return 1
+	}
+	return s.weigher(key, value)
+}
+
+func (s *shard[K, V]) addWeight(delta int64) {
+	s.weight += delta
+	atomic.AddInt64(&s.cache.weight, delta)
+}
+
+func (s *shard[K, V]) exceedsLimit() bool {
+	return int64(len(s.entries)) > s.cacheLimit || (s.maxWeight > 0 && s.weight > s.maxWeight)
+}
+
+// evict removes keys chosen by the policy until the shard fits its limits,
+// candidate is the key just added or nil.
+func (s *shard[K, V]) evict(candidate *K, evictions []eviction[K, V]) []eviction[K, V] {
+	evictionPolicy := s.cache.evictionPolicy
+	for len(s.entries) > 0 && s.exceedsLimit() {
+		key, found := s.policy.victim(candidate)
+		if !found {
+			if candidate != nil && s.maxWeight > 0 && s.weight > s.maxWeight {
+				// only the new value is left, but it is heavier than maxWeight
+				s.cache.logger.Get().Warn("the weight of the key exceeds the max weight", logger.Any("key", *candidate), logger.Any("maxWeight", s.maxWeight))
+				evictions = s.delete(*candidate, ReasonCapacity, evictions)
+			} else if candidate != nil && s.cacheLimit == 0 && len(s.cache.shards) > 1 {
+				// a shard has no room when the cache limit is less than the
+				// shards, the new value is not kept or the cache would exceed it
+				s.cache.logger.Get().Warn("exceed the cache limit of the shard, delete the key", logger.Any("key", *candidate), logger.Any("cacheLimit", s.cacheLimit))
+				evictions = s.delete(*candidate, ReasonCapacity, evictions)
+			}
+			break
+		}
+		if int64(len(s.entries)) > s.cacheLimit {
+			s.cache.logger.Get().Warn("exceed the cache limit, delete the key", logger.Any("key", key), logger.Any("cacheLimit", s.cacheLimit), logger.Any("evictionPolicy", evictionPolicy))
+		} else {
+			s.cache.logger.Get().Warn("exceed the max weight, delete the key", logger.Any("key", key), logger.Any("maxWeight", s.maxWeight), logger.Any("evictionPolicy", evictionPolicy))
+		}
+		evictions = s.delete(key, ReasonCapacity, evictions)
+		if candidate != nil && key == *candidate {
+			break
+		}
+	}
+	return evictions
+}
+
+// delete appends the removed value to evictions.
+func (s *shard[K, V]) delete(key K, reason EvictionReason, evictions []eviction[K, V]) []eviction[K, V] {
+	e, found := s.entries[key]
+	if !found {
+		return evictions
+	}
+
+	delete(s.entries, key)
+	s.policy.remove(key)
+	atomic.AddInt64(&s.cache.size, -1)
+	s.addWeight(-e.weight)
+	atomic.AddInt64(&s.cache.counters.evictions[reason], 1)
+	return append(evictions, eviction[K, V]{key: key, value: e.value, reason: reason})
+}
+
+func (s *shard[K, V]) deleteExpired(now time.Time, evictions []eviction[K, V]) []eviction[K, V] {
+	for key, e := range s.entries {
+		if e.isExpired(now) {
+			evictions = s.delete(key, ReasonExpired, evictions)
+		}
+	}
+	return evictions
+}
+
+func (s *shard[K, V]) resize(cacheLimit int64, maxWeight int64) []eviction[K, V] {
+	s.cacheLimit = cacheLimit
+	s.maxWeight = maxWeight
+	s.policy.resize(cacheLimit)
+	return s.evict(nil, nil)
+}
+
+func (s *shard[K, V]) setWeigher(weigher func(key K, value V) int64) []eviction[K, V] {
+	s.weigher = weigher
+	var weight int64
+	for key, e := range s.entries {
+		e.weight = s.weigh(key, e.value)
+		weight += e.weight
+	}
+	s.addWeight(weight - s.weight)
+	return s.evict(nil, nil)
+}
+
+// splitLimit returns the part of limit for the i-th of n shards, the parts add
+// up to limit.
+func splitLimit(limit int64, i int, n int) int64 {
+	part := limit / int64(n)
+	if int64(i) < limit%int64(n) {
+		part++
+	}
+	return part
+}
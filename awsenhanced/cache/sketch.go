@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/maphash"
+	"math/bits"
+)
+
+const (
+	sketchDepth       = 4
+	sketchMaxCount    = 15
+	sketchMinWidth    = 64
+	sketchSampleRatio = 10
+)
+
+// countMinSketch estimates the frequency of the keys with 4 rows of saturating
+// counters. The counters are halved after a sample of increments so that the
+// old frequencies fade away.
+type countMinSketch struct {
+	rows      [sketchDepth][]uint8
+	mask      uint64
+	additions int64
+	sample    int64
+}
+
+func newCountMinSketch(cacheLimit int64) *countMinSketch {
+	width := uint64(sketchMinWidth)
+	if cacheLimit > sketchMinWidth {
+		width = 1 << bits.Len64(uint64(cacheLimit-1))
+	}
+
+	s := &countMinSketch{
+		mask:   width - 1,
+		sample: int64(width) * sketchSampleRatio,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+func (s *countMinSketch) index(hash uint64, row int) uint64 {
+	// every row remixes with its own seed, so that the rows of a narrow
+	// sketch do not collide together
+	h := hash + uint64(row+1)*0x9e3779b97f4a7c15
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	return h & s.mask
+}
+
+func (s *countMinSketch) increment(hash uint64) {
+	for i := range s.rows {
+		idx := s.index(hash, i)
+		if s.rows[i][idx] < sketchMaxCount {
+			s.rows[i][idx]++
+		}
+	}
+
+	s.additions++
+	if s.additions >= s.sample {
+		s.reset()
+	}
+}
+
+func (s *countMinSketch) estimate(hash uint64) uint8 {
+	min := uint8(sketchMaxCount)
+	for i := range s.rows {
+		if c := s.rows[i][s.index(hash, i)]; c < min {
+			min = c
+		}
+	}
+	return min
+}
+
+func (s *countMinSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
+
+// hashKey hashes a comparable key, the common key types are hashed without
+// allocation and the others by their fmt representation.
+func hashKey[K comparable](seed maphash.Seed, key K) uint64 {
+	var h maphash.Hash
+	h.SetSeed(seed)
+
+	var buf [8]byte
+	switch k := interface{}(key).(type) {
+	case string:
+		_, _ = h.WriteString(k)
+	case int:
+		binary.LittleEndian.PutUint64(buf[:], uint64(k))
+		_, _ = h.Write(buf[:])
+	case int64:
+		binary.LittleEndian.PutUint64(buf[:], uint64(k))
+		_, _ = h.Write(buf[:])
+	case uint64:
+		binary.LittleEndian.PutUint64(buf[:], k)
+		_, _ = h.Write(buf[:])
+	case int32:
+		binary.LittleEndian.PutUint64(buf[:], uint64(k))
+		_, _ = h.Write(buf[:])
+	case uint32:
+		binary.LittleEndian.PutUint64(buf[:], uint64(k))
+		_, _ = h.Write(buf[:])
+	default:
+		_, _ = h.WriteString(fmt.Sprintf("%#v", k))
+	}
+	return h.Sum64()
+}
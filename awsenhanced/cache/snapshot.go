@@ -0,0 +1,225 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/logger"
+)
+
+const (
+	snapshotFormat        = "aws-sdk-enhanced-go/cache"
+	snapshotFormatVersion = 1
+)
+
+// snapshotHeader is the first line of a snapshot, the entries follow it one
+// per line.
+type snapshotHeader struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	KeyType   string    `json:"keyType"`
+	ValueType string    `json:"valueType"`
+	CreatedAt time.Time `json:"createdAt"`
+	Entries   int       `json:"entries"`
+}
+
+type snapshotEntry struct {
+	Key       json.RawMessage `json:"key"`
+	Value     json.RawMessage `json:"value"`
+	AddedAt   time.Time       `json:"addedAt"`
+	ExpiresAt *time.Time      `json:"expiresAt,omitempty"`
+}
+
+// SetCodec sets how Snapshot encodes the values and Restore decodes them, the
+// encoded value must be JSON. encoding/json is used by default.
+func (c *Cache[K, V]) SetCodec(encode func(key K, value V) ([]byte, error), decode func(key K, data []byte) (V, error)) {
+	c.codecLock.Lock()
+	defer c.codecLock.Unlock()
+
+	c.encode = encode
+	c.decode = decode
+}
+
+func (c *Cache[K, V]) codec() (func(key K, value V) ([]byte, error), func(key K, data []byte) (V, error)) {
+	c.codecLock.RLock()
+	defer c.codecLock.RUnlock()
+
+	encode, decode := c.encode, c.decode
+	if encode == nil {
+		encode = func(_ K, value V) ([]byte, error) {
+			return json.Marshal(value)
+		}
+	}
+	if decode == nil {
+		decode = func(_ K, data []byte) (V, error) {
+			var value V
+			err := json.Unmarshal(data, &value)
+			return value, err
+		}
+	}
+	return encode, decode
+}
+
+func (c *Cache[K, V]) types() (string, string) {
+	var key K
+	var value V
+	return fmt.Sprintf("%T", key), fmt.Sprintf("%T", &value)[1:]
+}
+
+// Snapshot writes the entries that have not expired as JSON lines: a header
+// with the format version and the types of the keys and the values, then an
+// entry per line with its key, value and metadata.
+func (c *Cache[K, V]) Snapshot(w io.Writer) error {
+	now := c.clock.Now()
+	type item struct {
+		key   K
+		value V
+		meta  Meta
+	}
+
+	var items []item
+	for _, s := range c.shards {
+		s.lock.Lock()
+		for key, e := range s.entries {
+			if !e.isExpired(now) {
+				items = append(items, item{key: key, value: e.value, meta: Meta{AddedAt: e.addedAt, ExpiresAt: e.expiresAt}})
+			}
+		}
+		s.lock.Unlock()
+	}
+
+	keyType, valueType := c.types()
+	encoder := json.NewEncoder(w)
+	err := encoder.Encode(snapshotHeader{
+		Format:    snapshotFormat,
+		Version:   snapshotFormatVersion,
+		KeyType:   keyType,
+		ValueType: valueType,
+		CreatedAt: now,
+		Entries:   len(items),
+	})
+	if err != nil {
+		return err
+	}
+
+	encode, _ := c.codec()
+	for _, item := range items {
+		key, err := json.Marshal(item.key)
+		if err != nil {
+			return fmt.Errorf("encode key [%v] failed, %w", item.key, err)
+		}
+		value, err := encode(item.key, item.value)
+		if err != nil {
+			return fmt.Errorf("encode value of key [%v] failed, %w", item.key, err)
+		}
+		if !json.Valid(value) {
+			return fmt.Errorf("encode value of key [%v] failed, not JSON", item.key)
+		}
+
+		entry := snapshotEntry{Key: key, Value: value, AddedAt: item.meta.AddedAt}
+		if !item.meta.ExpiresAt.IsZero() {
+			entry.ExpiresAt = &item.meta.ExpiresAt
+		}
+		err = encoder.Encode(entry)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Restore adds the entries of a snapshot with their metadata, skipping the ones
+// that have expired since. An entry that can not be decoded is logged and
+// skipped, the others are still restored.
+func (c *Cache[K, V]) Restore(r io.Reader) error {
+	reader := bufio.NewReader(r)
+
+	line, err := readSnapshotLine(reader)
+	if err != nil {
+		return fmt.Errorf("decode snapshot header failed, %w", err)
+	}
+	var header snapshotHeader
+	err = json.Unmarshal(line, &header)
+	if err != nil {
+		return fmt.Errorf("decode snapshot header failed, %w", err)
+	}
+	if header.Format != snapshotFormat {
+		return fmt.Errorf("unknown snapshot format [%s]", header.Format)
+	}
+	if header.Version != snapshotFormatVersion {
+		return fmt.Errorf("unsupported snapshot version [%d]", header.Version)
+	}
+	keyType, valueType := c.types()
+	if header.KeyType != keyType || header.ValueType != valueType {
+		return fmt.Errorf("snapshot of [%s]%s can not be restored to [%s]%s", header.KeyType, header.ValueType, keyType, valueType)
+	}
+
+	_, decode := c.codec()
+	now := c.clock.Now()
+	for i := 0; ; i++ {
+		line, err = readSnapshotLine(reader)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read snapshot entry [%d] failed, %w", i, err)
+		}
+
+		err = c.restoreEntry(line, decode, now)
+		if err != nil {
+			c.logger.Get().Warn("skip the snapshot entry", logger.Any("entry", i), logger.Err(err))
+		}
+	}
+}
+
+func (c *Cache[K, V]) restoreEntry(line []byte, decode func(key K, data []byte) (V, error), now time.Time) error {
+	var entry snapshotEntry
+	err := json.Unmarshal(line, &entry)
+	if err != nil {
+		return fmt.Errorf("decode snapshot entry failed, %w", err)
+	}
+
+	var expiresAt time.Time
+	if entry.ExpiresAt != nil {
+		expiresAt = *entry.ExpiresAt
+		if !now.Before(expiresAt) {
+			return nil
+		}
+	}
+
+	var key K
+	err = json.Unmarshal(entry.Key, &key)
+	if err != nil {
+		return fmt.Errorf("decode key of snapshot entry failed, %w", err)
+	}
+	value, err := decode(key, entry.Value)
+	if err != nil {
+		return fmt.Errorf("decode value of key [%v] failed, %w", key, err)
+	}
+
+	s := c.shard(key)
+	s.lock.Lock()
+	evictions := s.add(key, value, entry.AddedAt, expiresAt)
+	s.lock.Unlock()
+
+	c.notify(evictions)
+	return nil
+}
+
+// readSnapshotLine returns the next line that is not blank, io.EOF after the last one.
+func readSnapshotLine(reader *bufio.Reader) ([]byte, error) {
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			return line, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
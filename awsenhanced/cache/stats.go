@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a snapshot of the statistics of a Cache.
+type Stats struct {
+	Hits   int64
+	Misses int64
+	// HitRatio is Hits / (Hits + Misses), 0 when there is no read
+	HitRatio float64
+	// Evictions counts the removed values by reason, the replaced values are
+	// counted by Replaces
+	Evictions map[EvictionReason]int64
+	// Adds counts the values added with a new key
+	Adds int64
+	// Replaces counts the values added with an existing key
+	Replaces   int64
+	Size       int64
+	CacheLimit int64
+	Weight     int64
+	MaxWeight  int64
+	// Loads and LoadErrors count the Loader calls of GetOrLoad
+	Loads           int64
+	LoadErrors      int64
+	AverageLoadTime time.Duration
+}
+
+// counters are maintained with atomics like size.
+type counters struct {
+	hits      int64
+	misses    int64
+	adds      int64
+	replaces  int64
+	evictions [ReasonReplaced]int64
+
+	loads      int64
+	loadErrors int64
+	loadNanos  int64
+}
+
+func (c *Cache[K, V]) Stats() Stats {
+	stats := Stats{
+		Hits:       atomic.LoadInt64(&c.counters.hits),
+		Misses:     atomic.LoadInt64(&c.counters.misses),
+		Evictions:  map[EvictionReason]int64{},
+		Adds:       atomic.LoadInt64(&c.counters.adds),
+		Replaces:   atomic.LoadInt64(&c.counters.replaces),
+		Size:       c.Len(),
+		CacheLimit: c.CacheLimit(),
+		Weight:     c.Weight(),
+		MaxWeight:  c.MaxWeight(),
+		Loads:      atomic.LoadInt64(&c.counters.loads),
+		LoadErrors: atomic.LoadInt64(&c.counters.loadErrors),
+	}
+
+	if calls := stats.Loads + stats.LoadErrors; calls > 0 {
+		stats.AverageLoadTime = time.Duration(atomic.LoadInt64(&c.counters.loadNanos) / calls)
+	}
+
+	if reads := stats.Hits + stats.Misses; reads > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(reads)
+	}
+
+	for reason := range c.counters.evictions {
+		stats.Evictions[EvictionReason(reason)] = atomic.LoadInt64(&c.counters.evictions[reason])
+	}
+
+	return stats
+}
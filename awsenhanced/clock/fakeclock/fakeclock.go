@@ -0,0 +1,210 @@
+package fakeclock
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/clock"
+)
+
+// Clock is a clock.Clock that only moves when Advance or Set is called, the
+// tickers and the timers fire then, synchronously, for every interval that has
+// passed. Like time.Ticker, a ticker drops the ticks its reader is too slow for.
+type Clock struct {
+	lock    sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	tickers map[*ticker]struct{}
+	// timers holds the timers that have not fired or been stopped
+	timers map[*timer]struct{}
+}
+
+// New returns a clock set at now.
+func New(now time.Time) *Clock {
+	c := &Clock{
+		now:     now,
+		tickers: map[*ticker]struct{}{},
+		timers:  map[*timer]struct{}{},
+	}
+	c.cond = sync.NewCond(&c.lock)
+	return c
+}
+
+func (c *Clock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.now
+}
+
+func (c *Clock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+func (c *Clock) NewTicker(d time.Duration) clock.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	t := &ticker{
+		clock:    c,
+		c:        make(chan time.Time, 1),
+		interval: d,
+		next:     c.now.Add(d),
+	}
+	c.tickers[t] = struct{}{}
+	c.cond.Broadcast()
+	return t
+}
+
+func (c *Clock) NewTimer(d time.Duration) clock.Timer {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	t := &timer{
+		clock: c,
+		c:     make(chan time.Time, 1),
+	}
+	t.reset(d)
+	return t
+}
+
+// Advance moves the clock forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.set(c.now.Add(d))
+}
+
+// Set moves the clock to now, the tickers do not fire if it goes backwards.
+func (c *Clock) Set(now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.set(now)
+}
+
+func (c *Clock) set(now time.Time) {
+	c.now = now
+	for t := range c.tickers {
+		for !t.next.After(now) {
+			select {
+			case t.c <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.interval)
+		}
+	}
+	for t := range c.timers {
+		t.fire()
+	}
+}
+
+// WaitForTickers blocks until n tickers are running, so that a test advances
+// the clock only after the code under test has started its tickers.
+func (c *Clock) WaitForTickers(n int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for len(c.tickers) < n {
+		c.cond.Wait()
+	}
+}
+
+// WaitForTimers blocks until n timers are running, i.e. created or reset and
+// neither fired nor stopped.
+func (c *Clock) WaitForTimers(n int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+type ticker struct {
+	clock    *Clock
+	c        chan time.Time
+	interval time.Duration
+	// next is guarded by the lock of the clock
+	next time.Time
+}
+
+func (t *ticker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *ticker) Stop() {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+
+	delete(t.clock.tickers, t)
+}
+
+func (t *ticker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+
+	t.interval = d
+	t.next = t.clock.now.Add(d)
+	t.clock.tickers[t] = struct{}{}
+	t.clock.cond.Broadcast()
+}
+
+type timer struct {
+	clock *Clock
+	c     chan time.Time
+	// at is guarded by the lock of the clock
+	at time.Time
+}
+
+func (t *timer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *timer) Stop() bool {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+
+	_, running := t.clock.timers[t]
+	delete(t.clock.timers, t)
+	return running
+}
+
+func (t *timer) Reset(d time.Duration) bool {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+
+	_, running := t.clock.timers[t]
+	t.reset(d)
+	return running
+}
+
+// reset must be called with the lock of the clock, like time.Timer a timer
+// reset with a non-positive duration fires at once.
+func (t *timer) reset(d time.Duration) {
+	t.at = t.clock.now.Add(d)
+	t.clock.timers[t] = struct{}{}
+	t.clock.cond.Broadcast()
+	t.fire()
+}
+
+// fire must be called with the lock of the clock.
+func (t *timer) fire() {
+	if t.at.After(t.clock.now) {
+		return
+	}
+	select {
+	case t.c <- t.at:
+	default:
+	}
+	delete(t.clock.timers, t)
+}
@@ -0,0 +1,111 @@
+package fakeclock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClock_Advance(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := New(start)
+	ticker := c.NewTicker(time.Minute)
+
+	c.Advance(time.Second * 59)
+	if e, a := start.Add(time.Second*59), c.Now(); !e.Equal(a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	select {
+	case tick := <-ticker.C():
+		t.Errorf("expected no tick, but received %v", tick)
+	default:
+	}
+
+	// the ticks the reader is too slow for are dropped
+	c.Advance(time.Minute * 3)
+	if e, a := start.Add(time.Minute), <-ticker.C(); !e.Equal(a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	select {
+	case tick := <-ticker.C():
+		t.Errorf("expected no tick, but received %v", tick)
+	default:
+	}
+
+	ticker.Reset(time.Hour)
+	c.Advance(time.Minute * 59)
+	ticker.Stop()
+	c.Advance(time.Hour)
+	select {
+	case tick := <-ticker.C():
+		t.Errorf("expected no tick, but received %v", tick)
+	default:
+	}
+	if e, a := time.Hour*2+time.Minute*2+time.Second*59, c.Since(start); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestClock_WaitForTickers(t *testing.T) {
+	c := New(time.Now())
+	go func() {
+		time.Sleep(time.Millisecond * 10)
+		c.NewTicker(time.Second)
+	}()
+	c.WaitForTickers(1)
+}
+
+func TestClock_Timer(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := New(start)
+	timer := c.NewTimer(time.Minute)
+
+	c.Advance(time.Second * 59)
+	select {
+	case tick := <-timer.C():
+		t.Errorf("expected no tick, but received %v", tick)
+	default:
+	}
+
+	// a timer fires once
+	c.Advance(time.Minute * 3)
+	if e, a := start.Add(time.Minute), <-timer.C(); !e.Equal(a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	c.Advance(time.Minute)
+	select {
+	case tick := <-timer.C():
+		t.Errorf("expected no tick, but received %v", tick)
+	default:
+	}
+	if e, a := false, timer.Stop(); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	if e, a := false, timer.Reset(time.Hour); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := true, timer.Stop(); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	c.Advance(time.Hour)
+	select {
+	case tick := <-timer.C():
+		t.Errorf("expected no tick, but received %v", tick)
+	default:
+	}
+
+	// a non-positive duration fires at once
+	timer.Reset(0)
+	if e, a := c.Now(), <-timer.C(); !e.Equal(a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestClock_WaitForTimers(t *testing.T) {
+	c := New(time.Now())
+	go func() {
+		time.Sleep(time.Millisecond * 10)
+		c.NewTimer(time.Second)
+	}()
+	c.WaitForTimers(1)
+}
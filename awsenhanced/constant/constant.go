@@ -3,4 +3,11 @@ package constant
 const (
 	RegionEnvName      = "REGION"
 	EnvironmentEnvName = "APP_CONFIG_ENV"
+	// LogLevelEnvName sets the initial level of the logger package, one of
+	// debug, info, warn and error
+	LogLevelEnvName = "APP_CONFIG_LOG_LEVEL"
+	// OverrideEnvPrefix is followed by the configuration name to override its
+	// content, and then by "__" separated keys to override a key path only,
+	// e.g. APPCONFIG_OVERRIDE_MY_CONFIG__DATABASE__HOST
+	OverrideEnvPrefix = "APPCONFIG_OVERRIDE_"
 )
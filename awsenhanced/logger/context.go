@@ -0,0 +1,59 @@
+package logger
+
+import "context"
+
+type contextKey struct{}
+
+// contextValue is what a context carries for the logger package.
+type contextValue struct {
+	logger Logger
+	fields []Field
+}
+
+func fromContext(ctx context.Context) contextValue {
+	if ctx == nil {
+		return contextValue{}
+	}
+	v, _ := ctx.Value(contextKey{}).(contextValue)
+	return v
+}
+
+// NewContext returns a copy of ctx carrying l, the library logs the entries
+// of the calls made with the context with l instead of the logger of the
+// client. The fields already carried by ctx are kept.
+func NewContext(ctx context.Context, l Logger) context.Context {
+	v := fromContext(ctx)
+	v.logger = l
+	return context.WithValue(ctx, contextKey{}, v)
+}
+
+// ContextWithFields returns a copy of ctx carrying the fields, they are added
+// to the entries logged for the calls made with the context.
+func ContextWithFields(ctx context.Context, fields ...Field) context.Context {
+	v := fromContext(ctx)
+	v.fields = appendFields(v.fields, fields)
+	return context.WithValue(ctx, contextKey{}, v)
+}
+
+// ContextWithRequestID is ContextWithFields with the id of a request.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return ContextWithFields(ctx, RequestID(id))
+}
+
+// ContextWithTraceID is ContextWithFields with the id of a trace.
+func ContextWithTraceID(ctx context.Context, id string) context.Context {
+	return ContextWithFields(ctx, TraceID(id))
+}
+
+// FromContext returns the logger carried by ctx, l if it carries none, with
+// the fields carried by ctx. Like With, nil means the default logger.
+func FromContext(ctx context.Context, l Logger) Logger {
+	v := fromContext(ctx)
+	if v.logger != nil {
+		l = v.logger
+	}
+	if l != nil && len(v.fields) == 0 {
+		return l
+	}
+	return With(l, v.fields...)
+}
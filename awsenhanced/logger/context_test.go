@@ -0,0 +1,46 @@
+package logger
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFromContext(t *testing.T) {
+	defer SetDefault(nil)
+
+	def := &recordLogger{}
+	SetDefault(def)
+	client := &recordLogger{}
+	carried := &recordLogger{}
+
+	ctx := ContextWithRequestID(context.Background(), "req-1")
+	cases := []struct {
+		ctx      context.Context
+		logger   Logger
+		expected *recordLogger
+		fields   int
+	}{
+		{ctx: context.Background(), logger: client, expected: client},
+		{ctx: context.Background(), expected: def},
+		{ctx: ctx, logger: client, expected: client, fields: 1},
+		{ctx: ctx, expected: def, fields: 1},
+		{ctx: NewContext(ctx, carried), logger: client, expected: carried, fields: 1},
+		{ctx: ContextWithTraceID(NewContext(context.Background(), carried), "1-trace"), expected: carried, fields: 1},
+	}
+
+	for i, c := range cases {
+		c.expected.fields = nil
+		FromContext(c.ctx, c.logger).Info("a")
+		if e, a := c.fields, len(c.expected.fields); e != a {
+			t.Errorf("case %d, expected %v, but received %v", i, e, a)
+		}
+	}
+
+	// the fields of the parent contexts are kept
+	ctx = ContextWithTraceID(NewContext(ctx, carried), "1-trace")
+	carried.fields = nil
+	FromContext(ctx, client).Info("b")
+	if e, a := []Field{RequestID("req-1"), TraceID("1-trace")}, carried.fields; len(a) != 2 || e[0] != a[0] || e[1] != a[1] {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
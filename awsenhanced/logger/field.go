@@ -0,0 +1,63 @@
+package logger
+
+import "time"
+
+// The keys of the fields the library logs with.
+const (
+	ApplicationKey   = "application"
+	EnvironmentKey   = "environment"
+	ClientIDKey      = "clientId"
+	ConfigurationKey = "configuration"
+	VersionKey       = "version"
+	DurationKey      = "duration"
+	ErrorKey         = "error"
+	RequestIDKey     = "requestId"
+	TraceIDKey       = "traceId"
+)
+
+// Field is a key-value pair of a log entry.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Any is a field of any value, it is encoded by the adapted logger.
+func Any(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Application(name string) Field {
+	return Field{Key: ApplicationKey, Value: name}
+}
+
+func Environment(name string) Field {
+	return Field{Key: EnvironmentKey, Value: name}
+}
+
+func ClientID(id string) Field {
+	return Field{Key: ClientIDKey, Value: id}
+}
+
+func Configuration(name string) Field {
+	return Field{Key: ConfigurationKey, Value: name}
+}
+
+func Version(version string) Field {
+	return Field{Key: VersionKey, Value: version}
+}
+
+func Duration(d time.Duration) Field {
+	return Field{Key: DurationKey, Value: d}
+}
+
+func Err(err error) Field {
+	return Field{Key: ErrorKey, Value: err}
+}
+
+func RequestID(id string) Field {
+	return Field{Key: RequestIDKey, Value: id}
+}
+
+func TraceID(id string) Field {
+	return Field{Key: TraceIDKey, Value: id}
+}
@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/constant"
+)
+
+// Level is the severity of a log entry, the values are the ones of zap.
+type Level int8
+
+const (
+	DebugLevel Level = iota - 1
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+func (l Level) String() string {
+	switch l {
+	case DebugLevel:
+		return "debug"
+	case InfoLevel:
+		return "info"
+	case WarnLevel:
+		return "warn"
+	case ErrorLevel:
+		return "error"
+	default:
+		return fmt.Sprintf("Level(%d)", l)
+	}
+}
+
+// ParseLevel parses the name of a level, e.g. "debug" or "WARN".
+func ParseLevel(text string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(text)) {
+	case "debug":
+		return DebugLevel, nil
+	case "info":
+		return InfoLevel, nil
+	case "warn", "warning":
+		return WarnLevel, nil
+	case "error":
+		return ErrorLevel, nil
+	}
+	return InfoLevel, fmt.Errorf("unknown log level [%s]", text)
+}
+
+var level int32 = int32(InfoLevel)
+
+// initLevel reads the level from the env, info if it is not set.
+func initLevel() {
+	text, ok := os.LookupEnv(constant.LogLevelEnvName)
+	if !ok || text == "" {
+		return
+	}
+	l, err := ParseLevel(text)
+	if err != nil {
+		Warn("invalid env "+constant.LogLevelEnvName+", use the info level", Err(err))
+		return
+	}
+	SetLevel(l)
+}
+
+// SetLevel sets the lowest level logged by the adapters of this package, it
+// can be called at any time. The initial level is info, or the level of the
+// APP_CONFIG_LOG_LEVEL env.
+func SetLevel(l Level) {
+	atomic.StoreInt32(&level, int32(l))
+}
+
+// GetLevel returns the level set by SetLevel.
+func GetLevel() Level {
+	return Level(atomic.LoadInt32(&level))
+}
+
+// Enabled tells if the entries of the level are logged.
+func Enabled(l Level) bool {
+	return l >= GetLevel()
+}
@@ -1,77 +1,150 @@
 package logger
 
 import (
-	"os"
-
-	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
+	"sync/atomic"
 )
 
-type logger struct {
-	_logger *zap.SugaredLogger
+// Logger is what the library logs with, a message and the structured fields
+// of the entry. Adapters exist for zap, log/slog handlers, logrus and a
+// no-op, they drop the entries below the level set by SetLevel.
+type Logger interface {
+	Debug(msg string, fields ...Field)
+	Info(msg string, fields ...Field)
+	Warn(msg string, fields ...Field)
+	Error(msg string, fields ...Field)
+	// With returns a logger adding the fields to every entry.
+	With(fields ...Field) Logger
+}
+
+// Holder holds a Logger that can be replaced while it is used, the zero value
+// holds nothing and Get returns the default logger.
+type Holder struct {
+	value atomic.Value
+}
+
+// loggerBox lets a Holder store the Logger implementations of different types
+// in its atomic.Value.
+type loggerBox struct {
+	logger Logger
+}
+
+// Set replaces the held logger, nil means the default logger.
+func (h *Holder) Set(l Logger) {
+	h.value.Store(loggerBox{logger: l})
+}
+
+// Load returns the held logger, nil if it holds nothing or h is nil.
+func (h *Holder) Load() Logger {
+	if h == nil {
+		return nil
+	}
+	if box, ok := h.value.Load().(loggerBox); ok {
+		return box.logger
+	}
+	return nil
+}
+
+// Get returns the held logger, the default logger if it holds nothing.
+func (h *Holder) Get() Logger {
+	if l := h.Load(); l != nil {
+		return l
+	}
+	return Default()
 }
 
-var defaultLogger = &logger{
-	_logger: initSugaredLogger(),
+var defaultLogger atomic.Value
+
+func init() {
+	defaultLogger.Store(loggerBox{logger: newDefaultZap()})
+	initLevel()
+}
+
+// SetDefault replaces the logger used by the package functions and by the
+// clients created without WithLogger, nil restores the JSON zap logger
+// writing to stdout and stderr.
+func SetDefault(l Logger) {
+	if l == nil {
+		l = newDefaultZap()
+	}
+	defaultLogger.Store(loggerBox{logger: l})
 }
 
-func initSugaredLogger() *zap.SugaredLogger {
-	// First, define our level-handling logic.
-	highPriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-		return lvl >= zapcore.ErrorLevel
-	})
-	lowPriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-		return lvl < zapcore.ErrorLevel
-	})
+// Default returns the logger set by SetDefault.
+func Default() Logger {
+	return defaultLogger.Load().(loggerBox).logger
+}
 
-	// High-priority output should also go to standard error, and low-priority
-	// output should also go to standard out.
-	stdoutWriteSyncer := zapcore.Lock(os.Stdout)
-	stderrWriteSyncer := zapcore.Lock(os.Stderr)
+// With returns l with the fields. If l is nil, the returned logger logs with
+// the default logger at the time of each entry, so that SetDefault still
+// applies to it.
+func With(l Logger, fields ...Field) Logger {
+	if l == nil {
+		return defaultWith{fields: fields}
+	}
+	return l.With(fields...)
+}
 
-	productionEncoder := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
-	core := zapcore.NewTee(
-		zapcore.NewCore(productionEncoder, stderrWriteSyncer, highPriority),
-		zapcore.NewCore(productionEncoder, stdoutWriteSyncer, lowPriority),
-	)
+// callerSkip returns l reporting the caller n frames further up, for the
+// wrappers of this package.
+func callerSkip(l Logger, n int) Logger {
+	if s, ok := l.(interface{ callerSkip(n int) Logger }); ok {
+		return s.callerSkip(n)
+	}
+	return l
+}
 
-	// "zap.AddCallerSkip(1)" can locate the real caller because we wrap the zap logger
-	_zapLogger := zap.New(core, zap.WithCaller(true), zap.AddStacktrace(zapcore.ErrorLevel), zap.AddCallerSkip(1))
-	defer func(zapLogger *zap.Logger) {
-		_ = zapLogger.Sync() // flushes buffer, if any
-	}(_zapLogger)
+// defaultWith is the default logger with fields.
+type defaultWith struct {
+	fields []Field
+	// the frames of the wrappers of this package above it
+	skip int
+}
+
+func (l defaultWith) Debug(msg string, fields ...Field) {
+	callerSkip(Default(), 1+l.skip).Debug(msg, appendFields(l.fields, fields)...)
+}
+
+func (l defaultWith) Info(msg string, fields ...Field) {
+	callerSkip(Default(), 1+l.skip).Info(msg, appendFields(l.fields, fields)...)
+}
 
-	return _zapLogger.Sugar()
+func (l defaultWith) Warn(msg string, fields ...Field) {
+	callerSkip(Default(), 1+l.skip).Warn(msg, appendFields(l.fields, fields)...)
 }
 
-func Debug(args ...interface{}) {
-	defaultLogger.Debug(args...)
+func (l defaultWith) Error(msg string, fields ...Field) {
+	callerSkip(Default(), 1+l.skip).Error(msg, appendFields(l.fields, fields)...)
 }
 
-func Info(args ...interface{}) {
-	defaultLogger.Info(args...)
+func (l defaultWith) With(fields ...Field) Logger {
+	return defaultWith{fields: appendFields(l.fields, fields), skip: l.skip}
 }
 
-func Warn(args ...interface{}) {
-	defaultLogger.Warn(args...)
+func (l defaultWith) callerSkip(n int) Logger {
+	return defaultWith{fields: l.fields, skip: l.skip + n}
 }
 
-func Error(args ...interface{}) {
-	defaultLogger.Error(args...)
+// appendFields never appends to the backing array of a, which is shared.
+func appendFields(a []Field, b []Field) []Field {
+	if len(b) == 0 {
+		return a
+	}
+	fields := make([]Field, 0, len(a)+len(b))
+	return append(append(fields, a...), b...)
 }
 
-func (l *logger) Debug(args ...interface{}) {
-	l._logger.Debug(args...)
+func Debug(msg string, fields ...Field) {
+	callerSkip(Default(), 1).Debug(msg, fields...)
 }
 
-func (l *logger) Info(args ...interface{}) {
-	l._logger.Info(args...)
+func Info(msg string, fields ...Field) {
+	callerSkip(Default(), 1).Info(msg, fields...)
 }
 
-func (l *logger) Warn(args ...interface{}) {
-	l._logger.Warn(args...)
+func Warn(msg string, fields ...Field) {
+	callerSkip(Default(), 1).Warn(msg, fields...)
 }
 
-func (l *logger) Error(args ...interface{}) {
-	l._logger.Error(args...)
+func Error(msg string, fields ...Field) {
+	callerSkip(Default(), 1).Error(msg, fields...)
 }
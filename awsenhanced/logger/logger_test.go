@@ -0,0 +1,244 @@
+package logger
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newObservedZap() (Logger, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	return NewZap(zap.New(core, zap.WithCaller(true))), logs
+}
+
+func TestZap(t *testing.T) {
+	defer SetLevel(GetLevel())
+	SetLevel(InfoLevel)
+
+	l, logs := newObservedZap()
+	l = l.With(Application("app"))
+
+	l.Debug("debug")
+	l.Info("info", Configuration("my-config"), Duration(time.Second))
+	l.Warn("warn")
+	l.Error("error", Err(errors.New("boom")))
+
+	entries := logs.AllUntimed()
+	if e, a := 3, len(entries); e != a {
+		t.Fatalf("expected %v, but received %v", e, a)
+	}
+	if e, a := "info", entries[0].Message; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	fields := entries[0].ContextMap()
+	if e, a := "app", fields[ApplicationKey]; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := "my-config", fields[ConfigurationKey]; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := time.Second, fields[DurationKey]; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := "boom", entries[2].ContextMap()[ErrorKey]; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	for i, level := range []zapcore.Level{zapcore.InfoLevel, zapcore.WarnLevel, zapcore.ErrorLevel} {
+		if e, a := level, entries[i].Level; e != a {
+			t.Errorf("expected %v, but received %v", e, a)
+		}
+	}
+	// the caller is not the adapter
+	if e, a := "logger_test.go", filepath.Base(entries[0].Caller.File); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	defer SetLevel(GetLevel())
+
+	l, logs := newObservedZap()
+	cases := []struct {
+		level    Level
+		expected int
+	}{
+		{level: DebugLevel, expected: 4},
+		{level: InfoLevel, expected: 3},
+		{level: WarnLevel, expected: 2},
+		{level: ErrorLevel, expected: 1},
+	}
+
+	for i, c := range cases {
+		SetLevel(c.level)
+		l.Debug("debug")
+		l.Info("info")
+		l.Warn("warn")
+		l.Error("error")
+		if e, a := c.expected, len(logs.TakeAll()); e != a {
+			t.Errorf("case %d, expected %v, but received %v", i, e, a)
+		}
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	cases := []struct {
+		text     string
+		expected Level
+	}{
+		{text: "debug", expected: DebugLevel},
+		{text: "INFO", expected: InfoLevel},
+		{text: " warning ", expected: WarnLevel},
+		{text: "Error", expected: ErrorLevel},
+	}
+
+	for _, c := range cases {
+		level, err := ParseLevel(c.text)
+		if err != nil {
+			t.Errorf("%q, expected nil, but received %v", c.text, err)
+		}
+		if e, a := c.expected, level; e != a {
+			t.Errorf("%q, expected %v, but received %v", c.text, e, a)
+		}
+		if e, a := c.expected, mustParse(t, level.String()); e != a {
+			t.Errorf("%q, expected %v, but received %v", c.text, e, a)
+		}
+	}
+
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Errorf("expected error, but received nil")
+	}
+}
+
+func mustParse(t *testing.T, text string) Level {
+	level, err := ParseLevel(text)
+	if err != nil {
+		t.Fatalf("expected nil, but received %v", err)
+	}
+	return level
+}
+
+type recordLogger struct {
+	messages []string
+	fields   []Field
+}
+
+func (l *recordLogger) record(msg string, fields []Field) {
+	l.messages = append(l.messages, msg)
+	l.fields = append(l.fields, fields...)
+}
+
+func (l *recordLogger) Debug(msg string, fields ...Field) { l.record(msg, fields) }
+func (l *recordLogger) Info(msg string, fields ...Field)  { l.record(msg, fields) }
+func (l *recordLogger) Warn(msg string, fields ...Field)  { l.record(msg, fields) }
+func (l *recordLogger) Error(msg string, fields ...Field) { l.record(msg, fields) }
+func (l *recordLogger) With(fields ...Field) Logger {
+	return recordWith{recordLogger: l, with: fields}
+}
+
+// recordWith records the entries in its recordLogger with its fields.
+type recordWith struct {
+	*recordLogger
+	with []Field
+}
+
+func (l recordWith) Debug(msg string, fields ...Field) { l.record(msg, appendFields(l.with, fields)) }
+func (l recordWith) Info(msg string, fields ...Field)  { l.record(msg, appendFields(l.with, fields)) }
+func (l recordWith) Warn(msg string, fields ...Field)  { l.record(msg, appendFields(l.with, fields)) }
+func (l recordWith) Error(msg string, fields ...Field) { l.record(msg, appendFields(l.with, fields)) }
+
+func (l recordWith) With(fields ...Field) Logger {
+	return recordWith{recordLogger: l.recordLogger, with: appendFields(l.with, fields)}
+}
+
+func TestSetDefault(t *testing.T) {
+	defer SetDefault(nil)
+
+	l := &recordLogger{}
+	SetDefault(l)
+	Debug("a")
+	Info("b")
+	Warn("c")
+	Error("d")
+	if e, a := 4, len(l.messages); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	SetDefault(nil)
+	if _, ok := Default().(*zapLogger); !ok {
+		t.Errorf("expected the zap logger, but received %T", Default())
+	}
+}
+
+func TestWith(t *testing.T) {
+	defer SetDefault(nil)
+
+	// the default logger is taken at the time of each entry
+	l := With(nil, Application("app")).With(Environment("test"))
+	first := &recordLogger{}
+	SetDefault(first)
+	l.Info("a", Version("1"))
+	second := &recordLogger{}
+	SetDefault(second)
+	l.Info("b")
+
+	if e, a := []string{"a"}, first.messages; len(a) != 1 || e[0] != a[0] {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := []Field{Application("app"), Environment("test"), Version("1")}, first.fields; len(a) != 3 || e[0] != a[0] || e[1] != a[1] || e[2] != a[2] {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := []string{"b"}, second.messages; len(a) != 1 || e[0] != a[0] {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	// the caller is not the wrapper
+	observed, logs := newObservedZap()
+	SetDefault(observed)
+	l.Warn("c")
+	Warn("d")
+	for _, entry := range logs.AllUntimed() {
+		if e, a := "logger_test.go", filepath.Base(entry.Caller.File); e != a {
+			t.Errorf("expected %v, but received %v", e, a)
+		}
+	}
+}
+
+func TestHolder(t *testing.T) {
+	defer SetDefault(nil)
+
+	var h Holder
+	if h.Load() != nil {
+		t.Errorf("expected nil, but received %v", h.Load())
+	}
+
+	// the zero holder follows the default logger
+	l := &recordLogger{}
+	SetDefault(l)
+	h.Get().Info("a")
+	if e, a := 1, len(l.messages); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	h.Set(Nop())
+	h.Get().Info("b")
+	if e, a := 1, len(l.messages); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	h.Set(nil)
+	h.Get().Info("c")
+	if e, a := 2, len(l.messages); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	var nilHolder *Holder
+	nilHolder.Get().Info("d")
+	if e, a := 3, len(l.messages); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
@@ -0,0 +1,56 @@
+// Package logruslogger adapts logrus to the Logger of the logger package, it
+// is apart so that only its users depend on logrus.
+package logruslogger
+
+import (
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/logger"
+	"github.com/sirupsen/logrus"
+)
+
+type logrusLogger struct {
+	_logger logrus.FieldLogger
+}
+
+// New adapts a *logrus.Logger or a *logrus.Entry.
+func New(l logrus.FieldLogger) logger.Logger {
+	return &logrusLogger{_logger: l}
+}
+
+func (l *logrusLogger) entry(fields []logger.Field) logrus.FieldLogger {
+	if len(fields) == 0 {
+		return l._logger
+	}
+	logrusFields := make(logrus.Fields, len(fields))
+	for _, field := range fields {
+		logrusFields[field.Key] = field.Value
+	}
+	return l._logger.WithFields(logrusFields)
+}
+
+func (l *logrusLogger) Debug(msg string, fields ...logger.Field) {
+	if logger.Enabled(logger.DebugLevel) {
+		l.entry(fields).Debug(msg)
+	}
+}
+
+func (l *logrusLogger) Info(msg string, fields ...logger.Field) {
+	if logger.Enabled(logger.InfoLevel) {
+		l.entry(fields).Info(msg)
+	}
+}
+
+func (l *logrusLogger) Warn(msg string, fields ...logger.Field) {
+	if logger.Enabled(logger.WarnLevel) {
+		l.entry(fields).Warn(msg)
+	}
+}
+
+func (l *logrusLogger) Error(msg string, fields ...logger.Field) {
+	if logger.Enabled(logger.ErrorLevel) {
+		l.entry(fields).Error(msg)
+	}
+}
+
+func (l *logrusLogger) With(fields ...logger.Field) logger.Logger {
+	return &logrusLogger{_logger: l.entry(fields)}
+}
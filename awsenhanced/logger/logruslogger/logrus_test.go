@@ -0,0 +1,35 @@
+package logruslogger
+
+import (
+	"testing"
+
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/logger"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestLogrus(t *testing.T) {
+	l, hook := test.NewNullLogger()
+	l.SetLevel(logrus.InfoLevel)
+	adapter := New(l.WithField("component", "appconfig")).With(logger.Application("app"))
+
+	adapter.Debug("debug")
+	adapter.Info("info", logger.Configuration("my-config"))
+	adapter.Error("error")
+
+	entries := hook.AllEntries()
+	if e, a := 2, len(entries); e != a {
+		t.Fatalf("expected %v, but received %v", e, a)
+	}
+	if e, a := "info", entries[0].Message; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	for key, expected := range map[string]string{"component": "appconfig", logger.ApplicationKey: "app", logger.ConfigurationKey: "my-config"} {
+		if e, a := expected, entries[0].Data[key]; e != a {
+			t.Errorf("%s, expected %v, but received %v", key, e, a)
+		}
+	}
+	if e, a := logrus.ErrorLevel, entries[1].Level; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
@@ -0,0 +1,20 @@
+package logger
+
+type nopLogger struct{}
+
+// Nop returns a logger that discards everything.
+func Nop() Logger {
+	return nopLogger{}
+}
+
+func (nopLogger) Debug(string, ...Field) {}
+
+func (nopLogger) Info(string, ...Field) {}
+
+func (nopLogger) Warn(string, ...Field) {}
+
+func (nopLogger) Error(string, ...Field) {}
+
+func (l nopLogger) With(...Field) Logger {
+	return l
+}
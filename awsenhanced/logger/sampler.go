@@ -0,0 +1,186 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/clock"
+)
+
+// Sampler logs the first entries of each level, message, configuration and
+// version in an interval, and how many it has dropped once it is over.
+type Sampler struct {
+	first    int
+	interval time.Duration
+	clock    clock.Clock
+
+	lock    sync.Mutex
+	windows map[sampleKey]*sampleWindow
+}
+
+type sampleKey struct {
+	level         Level
+	msg           string
+	configuration string
+	version       string
+}
+
+func (k *sampleKey) set(fields []Field) {
+	for _, field := range fields {
+		switch field.Key {
+		case ConfigurationKey:
+			k.configuration = fmt.Sprint(field.Value)
+		case VersionKey:
+			k.version = fmt.Sprint(field.Value)
+		}
+	}
+}
+
+// sampleWindow counts the entries of a key in an interval.
+type sampleWindow struct {
+	start   time.Time
+	count   int
+	dropped int
+	// the logger and the fields of the last dropped entry, the summary is
+	// logged with them
+	logger Logger
+	fields []Field
+}
+
+// NewSampler returns a Sampler logging the first entries of each key in each
+// interval. Flush should be called regularly, e.g. at every refresh, to log
+// the summaries of the keys which are no longer logged.
+func NewSampler(first int, interval time.Duration, clk clock.Clock) *Sampler {
+	if clk == nil {
+		clk = clock.New()
+	}
+	return &Sampler{
+		first:    first,
+		interval: interval,
+		clock:    clk,
+		windows:  map[sampleKey]*sampleWindow{},
+	}
+}
+
+// Wrap returns l sampled by s, the loggers returned by its With share s.
+func (s *Sampler) Wrap(l Logger) Logger {
+	// one more frame to skip for the wrapper
+	return &sampledLogger{sampler: s, logger: callerSkip(With(l), 1)}
+}
+
+// Flush logs the summaries of the intervals which are over and forgets them.
+func (s *Sampler) Flush() {
+	now := s.clock.Now()
+
+	var summaries []sampleSummary
+	s.lock.Lock()
+	for key, window := range s.windows {
+		if now.Sub(window.start) >= s.interval {
+			if window.dropped > 0 {
+				summaries = append(summaries, sampleSummary{key: key, window: *window})
+			}
+			delete(s.windows, key)
+		}
+	}
+	s.lock.Unlock()
+
+	for _, summary := range summaries {
+		s.log(summary)
+	}
+}
+
+// sampleSummary is the number of dropped entries of a key in an interval.
+type sampleSummary struct {
+	key    sampleKey
+	window sampleWindow
+}
+
+func (s *Sampler) log(summary sampleSummary) {
+	msg := fmt.Sprintf("%d [%s] entries dropped in last %v", summary.window.dropped, summary.key.msg, s.interval)
+	fields := appendFields(summary.window.fields, []Field{Any("dropped", summary.window.dropped)})
+	switch summary.key.level {
+	case DebugLevel:
+		summary.window.logger.Debug(msg, fields...)
+	case InfoLevel:
+		summary.window.logger.Info(msg, fields...)
+	case WarnLevel:
+		summary.window.logger.Warn(msg, fields...)
+	default:
+		summary.window.logger.Error(msg, fields...)
+	}
+}
+
+// allow counts an entry and tells if it is logged.
+func (s *Sampler) allow(l Logger, level Level, msg string, withFields []Field, fields []Field) bool {
+	key := sampleKey{level: level, msg: msg}
+	key.set(withFields)
+	key.set(fields)
+	now := s.clock.Now()
+
+	var last *sampleSummary
+	s.lock.Lock()
+	window, found := s.windows[key]
+	if found && now.Sub(window.start) >= s.interval {
+		if window.dropped > 0 {
+			last = &sampleSummary{key: key, window: *window}
+		}
+		found = false
+	}
+	if !found {
+		window = &sampleWindow{start: now}
+		s.windows[key] = window
+	}
+	window.count++
+	allowed := window.count <= s.first
+	if !allowed {
+		window.dropped++
+		window.logger = l
+		window.fields = fields
+	}
+	s.lock.Unlock()
+
+	if last != nil {
+		s.log(*last)
+	}
+	return allowed
+}
+
+type sampledLogger struct {
+	sampler *Sampler
+	logger  Logger
+	// fields given to With, they may be part of the key
+	fields []Field
+}
+
+func (l *sampledLogger) Debug(msg string, fields ...Field) {
+	if !Enabled(DebugLevel) || l.sampler.allow(l.logger, DebugLevel, msg, l.fields, fields) {
+		l.logger.Debug(msg, fields...)
+	}
+}
+
+func (l *sampledLogger) Info(msg string, fields ...Field) {
+	if !Enabled(InfoLevel) || l.sampler.allow(l.logger, InfoLevel, msg, l.fields, fields) {
+		l.logger.Info(msg, fields...)
+	}
+}
+
+func (l *sampledLogger) Warn(msg string, fields ...Field) {
+	if !Enabled(WarnLevel) || l.sampler.allow(l.logger, WarnLevel, msg, l.fields, fields) {
+		l.logger.Warn(msg, fields...)
+	}
+}
+
+func (l *sampledLogger) Error(msg string, fields ...Field) {
+	if !Enabled(ErrorLevel) || l.sampler.allow(l.logger, ErrorLevel, msg, l.fields, fields) {
+		l.logger.Error(msg, fields...)
+	}
+}
+
+func (l *sampledLogger) With(fields ...Field) Logger {
+	return &sampledLogger{
+		sampler: l.sampler,
+		logger:  l.logger.With(fields...),
+		fields:  appendFields(l.fields, fields),
+	}
+}
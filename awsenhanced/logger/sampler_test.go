@@ -0,0 +1,64 @@
+package logger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/clock/fakeclock"
+)
+
+func TestSampler(t *testing.T) {
+	clk := fakeclock.New(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	sampler := NewSampler(2, time.Minute, clk)
+	l := &recordLogger{}
+	sampled := sampler.Wrap(l)
+
+	for i := 0; i < 5; i++ {
+		sampled.Error("refresh fail", Configuration("a"))
+	}
+	// another configuration is another key
+	sampled.With(Configuration("b")).Error("refresh fail")
+	if e, a := 3, len(l.messages); e != a {
+		t.Fatalf("expected %v, but received %v", e, a)
+	}
+
+	// the dropped entries are counted when the interval is over
+	clk.Advance(time.Minute)
+	sampled.Error("refresh fail", Configuration("a"))
+	expected := []string{"refresh fail", "refresh fail", "refresh fail", "3 [refresh fail] entries dropped in last 1m0s", "refresh fail"}
+	if e, a := expected, l.messages; len(e) != len(a) || e[3] != a[3] || e[4] != a[4] {
+		t.Fatalf("expected %v, but received %v", e, a)
+	}
+
+	// Flush logs the summaries of the keys no longer logged
+	sampled.Error("refresh fail", Configuration("a"))
+	sampled.Error("refresh fail", Configuration("a"))
+	sampler.Flush()
+	if e, a := 6, len(l.messages); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	clk.Advance(time.Minute)
+	sampler.Flush()
+	if e, a := "1 [refresh fail] entries dropped in last 1m0s", l.messages[len(l.messages)-1]; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := 0, len(sampler.windows); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestSampler_Level(t *testing.T) {
+	defer SetLevel(GetLevel())
+	SetLevel(WarnLevel)
+
+	clk := fakeclock.New(time.Now())
+	sampler := NewSampler(1, time.Minute, clk)
+	sampled := sampler.Wrap(&recordLogger{})
+
+	// the entries below the level are not counted
+	sampled.Debug("debug")
+	sampled.Info("info")
+	if e, a := 0, len(sampler.windows); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
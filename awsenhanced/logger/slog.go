@@ -0,0 +1,67 @@
+//go:build go1.21
+
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+)
+
+type slogLogger struct {
+	handler slog.Handler
+	skip    int
+}
+
+// NewSlog adapts a log/slog handler, e.g. slog.Default().Handler(). The
+// source of a record is the caller of the library.
+func NewSlog(handler slog.Handler) Logger {
+	return &slogLogger{handler: handler}
+}
+
+func slogAttrs(fields []Field) []slog.Attr {
+	attrs := make([]slog.Attr, len(fields))
+	for i, field := range fields {
+		attrs[i] = slog.Any(field.Key, field.Value)
+	}
+	return attrs
+}
+
+func (l *slogLogger) log(level Level, slogLevel slog.Level, msg string, fields []Field) {
+	ctx := context.Background()
+	if !Enabled(level) || !l.handler.Enabled(ctx, slogLevel) {
+		return
+	}
+
+	// skip runtime.Callers, log and the method of the level
+	var pcs [1]uintptr
+	runtime.Callers(3+l.skip, pcs[:])
+	record := slog.NewRecord(time.Now(), slogLevel, msg, pcs[0])
+	record.AddAttrs(slogAttrs(fields)...)
+	_ = l.handler.Handle(ctx, record)
+}
+
+func (l *slogLogger) Debug(msg string, fields ...Field) {
+	l.log(DebugLevel, slog.LevelDebug, msg, fields)
+}
+
+func (l *slogLogger) Info(msg string, fields ...Field) {
+	l.log(InfoLevel, slog.LevelInfo, msg, fields)
+}
+
+func (l *slogLogger) Warn(msg string, fields ...Field) {
+	l.log(WarnLevel, slog.LevelWarn, msg, fields)
+}
+
+func (l *slogLogger) Error(msg string, fields ...Field) {
+	l.log(ErrorLevel, slog.LevelError, msg, fields)
+}
+
+func (l *slogLogger) callerSkip(n int) Logger {
+	return &slogLogger{handler: l.handler, skip: l.skip + n}
+}
+
+func (l *slogLogger) With(fields ...Field) Logger {
+	return &slogLogger{handler: l.handler.WithAttrs(slogAttrs(fields)), skip: l.skip}
+}
@@ -0,0 +1,49 @@
+//go:build go1.21
+
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"testing"
+)
+
+func TestSlog(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewSlog(slog.NewJSONHandler(&buf, &slog.HandlerOptions{AddSource: true, Level: slog.LevelInfo}))
+	l = l.With(Application("app"))
+
+	l.Debug("debug")
+	l.Warn("warn", Configuration("my-config"))
+
+	var record struct {
+		Level         string `json:"level"`
+		Msg           string `json:"msg"`
+		Application   string `json:"application"`
+		Configuration string `json:"configuration"`
+		Source        struct {
+			File string `json:"file"`
+		} `json:"source"`
+	}
+	err := json.Unmarshal(buf.Bytes(), &record)
+	if err != nil {
+		t.Fatalf("expected nil, but received %v", err)
+	}
+	if e, a := "WARN", record.Level; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := "warn", record.Msg; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := "app", record.Application; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := "my-config", record.Configuration; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := "slog_test.go", filepath.Base(record.Source.File); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
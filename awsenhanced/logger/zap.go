@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"os"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type zapLogger struct {
+	_logger *zap.Logger
+}
+
+// NewZap adapts a zap logger, the caller of the library is reported instead
+// of the adapter.
+func NewZap(l *zap.Logger) Logger {
+	// "zap.AddCallerSkip(1)" can locate the real caller because we wrap the zap logger
+	return &zapLogger{
+		_logger: l.WithOptions(zap.AddCallerSkip(1)),
+	}
+}
+
+func zapFields(fields []Field) []zap.Field {
+	if len(fields) == 0 {
+		return nil
+	}
+	zapFields := make([]zap.Field, len(fields))
+	for i, field := range fields {
+		zapFields[i] = zap.Any(field.Key, field.Value)
+	}
+	return zapFields
+}
+
+func newDefaultZap() Logger {
+	// First, define our level-handling logic.
+	highPriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
+		return lvl >= zapcore.ErrorLevel
+	})
+	lowPriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
+		return lvl < zapcore.ErrorLevel
+	})
+
+	// High-priority output should also go to standard error, and low-priority
+	// output should also go to standard out.
+	stdoutWriteSyncer := zapcore.Lock(os.Stdout)
+	stderrWriteSyncer := zapcore.Lock(os.Stderr)
+
+	productionEncoder := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	core := zapcore.NewTee(
+		zapcore.NewCore(productionEncoder, stderrWriteSyncer, highPriority),
+		zapcore.NewCore(productionEncoder, stdoutWriteSyncer, lowPriority),
+	)
+
+	// the errors mostly come from AWS, only the panics need a stacktrace
+	_zapLogger := zap.New(core, zap.WithCaller(true), zap.AddStacktrace(zapcore.DPanicLevel))
+	defer func(zapLogger *zap.Logger) {
+		_ = zapLogger.Sync() // flushes buffer, if any
+	}(_zapLogger)
+
+	return NewZap(_zapLogger)
+}
+
+func (l *zapLogger) Debug(msg string, fields ...Field) {
+	if Enabled(DebugLevel) {
+		l._logger.Debug(msg, zapFields(fields)...)
+	}
+}
+
+func (l *zapLogger) Info(msg string, fields ...Field) {
+	if Enabled(InfoLevel) {
+		l._logger.Info(msg, zapFields(fields)...)
+	}
+}
+
+func (l *zapLogger) Warn(msg string, fields ...Field) {
+	if Enabled(WarnLevel) {
+		l._logger.Warn(msg, zapFields(fields)...)
+	}
+}
+
+func (l *zapLogger) Error(msg string, fields ...Field) {
+	if Enabled(ErrorLevel) {
+		l._logger.Error(msg, zapFields(fields)...)
+	}
+}
+
+func (l *zapLogger) callerSkip(n int) Logger {
+	return &zapLogger{_logger: l._logger.WithOptions(zap.AddCallerSkip(n))}
+}
+
+func (l *zapLogger) With(fields ...Field) Logger {
+	return &zapLogger{_logger: l._logger.With(zapFields(fields)...)}
+}
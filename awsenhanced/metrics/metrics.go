@@ -0,0 +1,74 @@
+// Package metrics is what the library records its metrics with, adapters for
+// Prometheus and OpenTelemetry are in the prommetrics and otelmetrics
+// packages so that only their users depend on them.
+package metrics
+
+// Provider creates the instruments. The instruments created with the same
+// name are the same, e.g. when several clients record to one Provider, so
+// the label names of a name never change.
+type Provider interface {
+	Counter(name string, help string, labelNames ...string) Counter
+	Gauge(name string, help string, labelNames ...string) Gauge
+	// Histogram records durations in seconds.
+	Histogram(name string, help string, labelNames ...string) Histogram
+	// GaugeFunc registers f, called when the metrics are collected, the
+	// returned func unregisters it.
+	GaugeFunc(name string, help string, f GaugeFunc, labelNames ...string) (unregister func())
+}
+
+// Counter is a value that only goes up, the label values are in the order of
+// the label names.
+type Counter interface {
+	Add(delta float64, labelValues ...string)
+}
+
+// Gauge is a value that goes up and down.
+type Gauge interface {
+	Set(value float64, labelValues ...string)
+	// Delete removes the value of the label values, which is no longer
+	// exported, e.g. when the configuration it is about is no longer cached.
+	Delete(labelValues ...string)
+}
+
+// GaugeFunc reports the current values of a gauge with observe, the label
+// values are in the order of the label names.
+type GaugeFunc func(observe func(value float64, labelValues ...string))
+
+// Histogram records the distribution of values.
+type Histogram interface {
+	Observe(value float64, labelValues ...string)
+}
+
+type nopProvider struct{}
+
+type nopInstrument struct{}
+
+// Nop returns a Provider whose instruments record nothing, it is used when
+// no Provider is set.
+func Nop() Provider {
+	return nopProvider{}
+}
+
+func (nopProvider) Counter(string, string, ...string) Counter {
+	return nopInstrument{}
+}
+
+func (nopProvider) Gauge(string, string, ...string) Gauge {
+	return nopInstrument{}
+}
+
+func (nopProvider) Histogram(string, string, ...string) Histogram {
+	return nopInstrument{}
+}
+
+func (nopProvider) GaugeFunc(string, string, GaugeFunc, ...string) func() {
+	return func() {}
+}
+
+func (nopInstrument) Add(float64, ...string) {}
+
+func (nopInstrument) Set(float64, ...string) {}
+
+func (nopInstrument) Delete(...string) {}
+
+func (nopInstrument) Observe(float64, ...string) {}
@@ -0,0 +1,213 @@
+// Package otelmetrics adapts an OpenTelemetry meter to the Provider of the
+// metrics package.
+package otelmetrics
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/metrics"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+)
+
+type provider struct {
+	meter metric.Meter
+
+	lock        sync.Mutex
+	instruments map[string]interface{}
+}
+
+// New adapts a meter, e.g. global.Meter("appconfig"). The errors of the
+// meter are given to otel.Handle and the instrument records nothing.
+func New(meter metric.Meter) metrics.Provider {
+	return &provider{
+		meter:       meter,
+		instruments: map[string]interface{}{},
+	}
+}
+
+// instrument returns the instrument of name, it is created by newInstrument
+// if it does not exist.
+func (p *provider) instrument(name string, newInstrument func() (interface{}, error)) interface{} {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if i, found := p.instruments[name]; found {
+		return i
+	}
+	i, err := newInstrument()
+	if err != nil {
+		otel.Handle(err)
+		return nil
+	}
+	p.instruments[name] = i
+	return i
+}
+
+func attributes(labelNames []string, labelValues []string) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(labelNames))
+	for i, name := range labelNames {
+		if i < len(labelValues) {
+			attrs = append(attrs, attribute.String(name, labelValues[i]))
+		}
+	}
+	return attrs
+}
+
+func (p *provider) Counter(name string, help string, labelNames ...string) metrics.Counter {
+	c, ok := p.instrument(name, func() (interface{}, error) {
+		c, err := p.meter.Float64Counter(name, instrument.WithDescription(help))
+		return &counter{counter: c, labelNames: labelNames}, err
+	}).(*counter)
+	if !ok {
+		return metrics.Nop().Counter(name, help, labelNames...)
+	}
+	return c
+}
+
+func (p *provider) Histogram(name string, help string, labelNames ...string) metrics.Histogram {
+	h, ok := p.instrument(name, func() (interface{}, error) {
+		h, err := p.meter.Float64Histogram(name, instrument.WithDescription(help), instrument.WithUnit("s"))
+		return &histogram{histogram: h, labelNames: labelNames}, err
+	}).(*histogram)
+	if !ok {
+		return metrics.Nop().Histogram(name, help, labelNames...)
+	}
+	return h
+}
+
+// Gauge is an observable gauge reporting the last value set for each label
+// values, OpenTelemetry has no synchronous gauge.
+func (p *provider) Gauge(name string, help string, labelNames ...string) metrics.Gauge {
+	g, ok := p.instrument(name, func() (interface{}, error) {
+		g := &gauge{labelNames: labelNames, values: map[string]gaugeValue{}}
+		_, err := p.meter.Float64ObservableGauge(name,
+			instrument.WithDescription(help),
+			instrument.WithFloat64Callback(g.observe),
+		)
+		return g, err
+	}).(*gauge)
+	if !ok {
+		return metrics.Nop().Gauge(name, help, labelNames...)
+	}
+	return g
+}
+
+// GaugeFunc is an observable gauge calling the registered functions.
+func (p *provider) GaugeFunc(name string, help string, f metrics.GaugeFunc, labelNames ...string) func() {
+	g, ok := p.instrument(name, func() (interface{}, error) {
+		g := &gaugeFuncs{labelNames: labelNames, funcs: map[int]metrics.GaugeFunc{}}
+		_, err := p.meter.Float64ObservableGauge(name,
+			instrument.WithDescription(help),
+			instrument.WithFloat64Callback(g.observe),
+		)
+		return g, err
+	}).(*gaugeFuncs)
+	if !ok {
+		return metrics.Nop().GaugeFunc(name, help, f, labelNames...)
+	}
+	return g.register(f)
+}
+
+type counter struct {
+	counter    instrument.Float64Counter
+	labelNames []string
+}
+
+func (c *counter) Add(delta float64, labelValues ...string) {
+	c.counter.Add(context.Background(), delta, attributes(c.labelNames, labelValues)...)
+}
+
+type histogram struct {
+	histogram  instrument.Float64Histogram
+	labelNames []string
+}
+
+func (h *histogram) Observe(value float64, labelValues ...string) {
+	h.histogram.Record(context.Background(), value, attributes(h.labelNames, labelValues)...)
+}
+
+type gaugeValue struct {
+	value float64
+	attrs []attribute.KeyValue
+}
+
+type gauge struct {
+	labelNames []string
+
+	lock   sync.Mutex
+	values map[string]gaugeValue
+}
+
+func (g *gauge) Set(value float64, labelValues ...string) {
+	key := gaugeKey(labelValues)
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.values[key] = gaugeValue{value: value, attrs: attributes(g.labelNames, labelValues)}
+}
+
+func (g *gauge) Delete(labelValues ...string) {
+	key := gaugeKey(labelValues)
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	delete(g.values, key)
+}
+
+func gaugeKey(labelValues []string) string {
+	// \xff is never in a UTF-8 label value
+	return strings.Join(labelValues, "\xff")
+}
+
+func (g *gauge) observe(_ context.Context, observer instrument.Float64Observer) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	for _, v := range g.values {
+		observer.Observe(v.value, v.attrs...)
+	}
+	return nil
+}
+
+type gaugeFuncs struct {
+	labelNames []string
+
+	lock   sync.Mutex
+	funcs  map[int]metrics.GaugeFunc
+	nextID int
+}
+
+func (g *gaugeFuncs) register(f metrics.GaugeFunc) func() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	id := g.nextID
+	g.nextID++
+	g.funcs[id] = f
+	return func() {
+		g.lock.Lock()
+		defer g.lock.Unlock()
+		delete(g.funcs, id)
+	}
+}
+
+func (g *gaugeFuncs) observe(_ context.Context, observer instrument.Float64Observer) error {
+	g.lock.Lock()
+	funcs := make([]metrics.GaugeFunc, 0, len(g.funcs))
+	for _, f := range g.funcs {
+		funcs = append(funcs, f)
+	}
+	g.lock.Unlock()
+
+	for _, f := range funcs {
+		f(func(value float64, labelValues ...string) {
+			observer.Observe(value, attributes(g.labelNames, labelValues)...)
+		})
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package otelmetrics
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// collect returns the values of the sums and the gauges and the counts of the
+// histograms by name and attributes.
+func collect(t *testing.T, reader sdkmetric.Reader) map[string]float64 {
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	values := map[string]float64{}
+	key := func(name string, attrs attribute.Set) string {
+		return name + "{" + attrs.Encoded(attribute.DefaultEncoder()) + "}"
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[float64]:
+				for _, dp := range data.DataPoints {
+					values[key(m.Name, dp.Attributes)] = dp.Value
+				}
+			case metricdata.Gauge[float64]:
+				for _, dp := range data.DataPoints {
+					values[key(m.Name, dp.Attributes)] = dp.Value
+				}
+			case metricdata.Histogram:
+				for _, dp := range data.DataPoints {
+					values[key(m.Name, dp.Attributes)] = float64(dp.Count)
+				}
+			}
+		}
+	}
+	return values
+}
+
+func TestProvider(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := New(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test"))
+
+	provider.Counter("requests_total", "", "configuration").Add(1, "a")
+	provider.Counter("requests_total", "", "configuration").Add(2, "a")
+	provider.Counter("requests_total", "", "configuration").Add(1, "b")
+	provider.Gauge("version", "", "configuration").Set(3, "a")
+	provider.Gauge("version", "", "configuration").Set(4, "a")
+	provider.Gauge("version", "", "configuration").Set(1, "b")
+	provider.Gauge("version", "", "configuration").Delete("b")
+	provider.Histogram("duration_seconds", "", "configuration").Observe(0.5, "a")
+	provider.Histogram("duration_seconds", "", "configuration").Observe(1.5, "a")
+	provider.GaugeFunc("stale_seconds", "", func(observe func(float64, ...string)) {
+		observe(10, "a")
+	}, "configuration")
+	unregister := provider.GaugeFunc("stale_seconds", "", func(observe func(float64, ...string)) {
+		observe(1, "b")
+	}, "configuration")
+	unregister()
+
+	expected := map[string]float64{
+		"requests_total{configuration=a}":   3,
+		"requests_total{configuration=b}":   1,
+		"version{configuration=a}":          4,
+		"duration_seconds{configuration=a}": 2,
+		"stale_seconds{configuration=a}":    10,
+	}
+	if e, a := expected, collect(t, reader); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
@@ -0,0 +1,177 @@
+// Package prommetrics adapts Prometheus to the Provider of the metrics
+// package.
+package prommetrics
+
+import (
+	"sync"
+
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector is a metrics.Provider and an unchecked prometheus.Collector, e.g.
+// prometheus.MustRegister(collector) then appconfig.WithMetrics(collector).
+type Collector struct {
+	namespace string
+
+	lock       sync.RWMutex
+	collectors map[string]prometheus.Collector
+}
+
+// New returns a Collector whose metric names are prefixed by namespace, which
+// can be empty.
+func New(namespace string) *Collector {
+	return &Collector{
+		namespace:  namespace,
+		collectors: map[string]prometheus.Collector{},
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	for _, collector := range c.collectors {
+		collector.Collect(ch)
+	}
+}
+
+// collector returns the collector of name, it is created by newCollector if
+// it does not exist.
+func (c *Collector) collector(name string, newCollector func() prometheus.Collector) prometheus.Collector {
+	c.lock.RLock()
+	collector, found := c.collectors[name]
+	c.lock.RUnlock()
+	if found {
+		return collector
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if collector, found = c.collectors[name]; !found {
+		collector = newCollector()
+		c.collectors[name] = collector
+	}
+	return collector
+}
+
+func (c *Collector) Counter(name string, help string, labelNames ...string) metrics.Counter {
+	vec, ok := c.collector(name, func() prometheus.Collector {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: c.namespace, Name: name, Help: help}, labelNames)
+	}).(*prometheus.CounterVec)
+	if !ok {
+		return metrics.Nop().Counter(name, help, labelNames...)
+	}
+	return counter{vec: vec}
+}
+
+func (c *Collector) Gauge(name string, help string, labelNames ...string) metrics.Gauge {
+	vec, ok := c.collector(name, func() prometheus.Collector {
+		return prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: c.namespace, Name: name, Help: help}, labelNames)
+	}).(*prometheus.GaugeVec)
+	if !ok {
+		return metrics.Nop().Gauge(name, help, labelNames...)
+	}
+	return gauge{vec: vec}
+}
+
+func (c *Collector) Histogram(name string, help string, labelNames ...string) metrics.Histogram {
+	vec, ok := c.collector(name, func() prometheus.Collector {
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{Namespace: c.namespace, Name: name, Help: help}, labelNames)
+	}).(*prometheus.HistogramVec)
+	if !ok {
+		return metrics.Nop().Histogram(name, help, labelNames...)
+	}
+	return histogram{vec: vec}
+}
+
+func (c *Collector) GaugeFunc(name string, help string, f metrics.GaugeFunc, labelNames ...string) func() {
+	funcs, ok := c.collector(name, func() prometheus.Collector {
+		return &gaugeFuncs{
+			desc:  prometheus.NewDesc(prometheus.BuildFQName(c.namespace, "", name), help, labelNames, nil),
+			funcs: map[int]metrics.GaugeFunc{},
+		}
+	}).(*gaugeFuncs)
+	if !ok {
+		return metrics.Nop().GaugeFunc(name, help, f, labelNames...)
+	}
+	return funcs.register(f)
+}
+
+type counter struct {
+	vec *prometheus.CounterVec
+}
+
+func (c counter) Add(delta float64, labelValues ...string) {
+	c.vec.WithLabelValues(labelValues...).Add(delta)
+}
+
+type gauge struct {
+	vec *prometheus.GaugeVec
+}
+
+func (g gauge) Set(value float64, labelValues ...string) {
+	g.vec.WithLabelValues(labelValues...).Set(value)
+}
+
+func (g gauge) Delete(labelValues ...string) {
+	g.vec.DeleteLabelValues(labelValues...)
+}
+
+type histogram struct {
+	vec *prometheus.HistogramVec
+}
+
+func (h histogram) Observe(value float64, labelValues ...string) {
+	h.vec.WithLabelValues(labelValues...).Observe(value)
+}
+
+// gaugeFuncs is a prometheus.Collector calling the functions of a gauge when
+// it is collected.
+type gaugeFuncs struct {
+	desc *prometheus.Desc
+
+	lock   sync.Mutex
+	funcs  map[int]metrics.GaugeFunc
+	nextID int
+}
+
+func (g *gaugeFuncs) register(f metrics.GaugeFunc) func() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	id := g.nextID
+	g.nextID++
+	g.funcs[id] = f
+	return func() {
+		g.lock.Lock()
+		defer g.lock.Unlock()
+		delete(g.funcs, id)
+	}
+}
+
+func (g *gaugeFuncs) Describe(ch chan<- *prometheus.Desc) {
+	ch <- g.desc
+}
+
+func (g *gaugeFuncs) Collect(ch chan<- prometheus.Metric) {
+	g.lock.Lock()
+	funcs := make([]metrics.GaugeFunc, 0, len(g.funcs))
+	for _, f := range g.funcs {
+		funcs = append(funcs, f)
+	}
+	g.lock.Unlock()
+
+	for _, f := range funcs {
+		f(func(value float64, labelValues ...string) {
+			metric, err := prometheus.NewConstMetric(g.desc, prometheus.GaugeValue, value, labelValues...)
+			if err == nil {
+				ch <- metric
+			}
+		})
+	}
+}
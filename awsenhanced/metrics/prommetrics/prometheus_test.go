@@ -0,0 +1,61 @@
+package prommetrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	collector := New("test")
+	registry := prometheus.NewPedanticRegistry()
+	if err := registry.Register(collector); err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	collector.Counter("requests_total", "The requests.", "configuration").Add(1, "a")
+	collector.Counter("requests_total", "The requests.", "configuration").Add(2, "a")
+	collector.Gauge("version", "The version.", "configuration").Set(3, "a")
+	collector.Gauge("version", "The version.", "configuration").Set(1, "b")
+	collector.Gauge("version", "The version.", "configuration").Delete("b")
+	collector.Histogram("duration_seconds", "The duration.", "configuration").Observe(0.5, "a")
+	stale := 10.0
+	collector.GaugeFunc("stale_seconds", "The staleness.", func(observe func(float64, ...string)) {
+		observe(stale, "a")
+	}, "configuration")
+	unregister := collector.GaugeFunc("stale_seconds", "The staleness.", func(observe func(float64, ...string)) {
+		observe(1, "b")
+	}, "configuration")
+	unregister()
+
+	expected := `
+# HELP test_requests_total The requests.
+# TYPE test_requests_total counter
+test_requests_total{configuration="a"} 3
+# HELP test_stale_seconds The staleness.
+# TYPE test_stale_seconds gauge
+test_stale_seconds{configuration="a"} 10
+# HELP test_version The version.
+# TYPE test_version gauge
+test_version{configuration="a"} 3
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "test_requests_total", "test_version", "test_stale_seconds"); err != nil {
+		t.Errorf("expected no error, but received %v", err)
+	}
+	// the functions report when the metrics are collected
+	stale = 20
+	if e, a := float64(20), testutil.ToFloat64(prometheus.Collector(collector.collectors["stale_seconds"])); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := 4, testutil.CollectAndCount(collector); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	// the instruments of a name keep its first type
+	collector.Gauge("requests_total", "The requests.", "configuration").Set(1, "a")
+	if e, a := float64(3), testutil.ToFloat64(prometheus.Collector(collector.collectors["requests_total"])); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
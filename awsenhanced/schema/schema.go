@@ -0,0 +1,142 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Schema is a compiled JSON Schema.
+type Schema struct {
+	source   string
+	compiled *jsonschema.Schema
+}
+
+// Compile compiles a JSON Schema document, the draft is taken from "$schema"
+// and defaults to 2020-12.
+func Compile(name string, source string) (*Schema, error) {
+	compiled, err := jsonschema.CompileString(name+".schema.json", source)
+	if err != nil {
+		return nil, fmt.Errorf("compile JSON schema of [%s] fail: %w", name, err)
+	}
+	return &Schema{
+		source:   source,
+		compiled: compiled,
+	}, nil
+}
+
+// CompileDraft4 compiles a JSON Schema document for AWS AppConfig, whose
+// JSON_SCHEMA validators only accept draft-04: the draft defaults to draft-04
+// and a "$schema" of another draft is refused.
+func CompileDraft4(name string, source string) (*Schema, error) {
+	var document struct {
+		Schema *string `json:"$schema"`
+	}
+	err := json.Unmarshal([]byte(source), &document)
+	if err != nil {
+		return nil, fmt.Errorf("compile JSON schema of [%s] fail: %w", name, err)
+	}
+	if document.Schema != nil && !isDraft4(*document.Schema) {
+		return nil, fmt.Errorf("compile JSON schema of [%s] fail: AppConfig only supports draft-04, but $schema is [%s]", name, *document.Schema)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft4
+	url := name + ".schema.json"
+	err = compiler.AddResource(url, strings.NewReader(source))
+	if err != nil {
+		return nil, fmt.Errorf("compile JSON schema of [%s] fail: %w", name, err)
+	}
+	compiled, err := compiler.Compile(url)
+	if err != nil {
+		return nil, fmt.Errorf("compile JSON schema of [%s] fail: %w", name, err)
+	}
+	return &Schema{
+		source:   source,
+		compiled: compiled,
+	}, nil
+}
+
+func isDraft4(url string) bool {
+	url = strings.TrimPrefix(strings.TrimPrefix(url, "http://"), "https://")
+	return strings.TrimSuffix(url, "#") == "json-schema.org/draft-04/schema"
+}
+
+// Source returns the JSON Schema document.
+func (s *Schema) Source() string {
+	return s.source
+}
+
+// Validate validates a JSON document against the schema.
+func (s *Schema) Validate(content string) error {
+	decoder := json.NewDecoder(strings.NewReader(content))
+	decoder.UseNumber()
+
+	var document interface{}
+	err := decoder.Decode(&document)
+	if err != nil {
+		return fmt.Errorf("content is not valid JSON: %w", err)
+	}
+
+	return s.compiled.Validate(document)
+}
+
+// Registry holds a Schema per configuration name, it is safe for concurrent use.
+type Registry struct {
+	compile func(name string, source string) (*Schema, error)
+	schemas sync.Map
+}
+
+func NewRegistry() *Registry {
+	return &Registry{compile: Compile}
+}
+
+// NewDraft4Registry returns a Registry that compiles the schemas with CompileDraft4.
+func NewDraft4Registry() *Registry {
+	return &Registry{compile: CompileDraft4}
+}
+
+// Register compiles and registers the schema of a configuration, replacing
+// the previous one.
+func (r *Registry) Register(configurationName string, source string) error {
+	compile := r.compile
+	if compile == nil {
+		compile = Compile
+	}
+	s, err := compile(configurationName, source)
+	if err != nil {
+		return err
+	}
+	r.schemas.Store(configurationName, s)
+	return nil
+}
+
+func (r *Registry) Unregister(configurationName string) {
+	r.schemas.Delete(configurationName)
+}
+
+func (r *Registry) Get(configurationName string) (*Schema, bool) {
+	s, found := r.schemas.Load(configurationName)
+	if !found {
+		return nil, false
+	}
+	return s.(*Schema), true
+}
+
+// Validate validates the content of a configuration, it passes when there is
+// no schema registered for the configuration.
+func (r *Registry) Validate(configurationName string, content string) error {
+	s, found := r.Get(configurationName)
+	if !found {
+		return nil
+	}
+
+	err := s.Validate(content)
+	if err != nil {
+		return fmt.Errorf("configuration [%s] does not match its JSON schema: %w", configurationName, err)
+	}
+	return nil
+}
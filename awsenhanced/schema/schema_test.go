@@ -0,0 +1,113 @@
+package schema
+
+import (
+	"testing"
+)
+
+const testSchema = `{
+	"$schema": "http://json-schema.org/draft-04/schema#",
+	"type": "object",
+	"properties": {
+		"name": {"type": "string"},
+		"port": {"type": "integer", "minimum": 1}
+	},
+	"required": ["name"]
+}`
+
+func TestRegistry_Validate(t *testing.T) {
+	cases := []struct {
+		configurationName string
+		content           string
+		wantErr           bool
+	}{
+		{
+			configurationName: "foo",
+			content:           `{"name": "foo", "port": 8080}`,
+			wantErr:           false,
+		},
+		{
+			configurationName: "foo",
+			content:           `{"port": 8080}`,
+			wantErr:           true,
+		},
+		{
+			configurationName: "foo",
+			content:           `{"name": "foo", "port": 0}`,
+			wantErr:           true,
+		},
+		{
+			configurationName: "foo",
+			content:           `not json`,
+			wantErr:           true,
+		},
+		{
+			// no schema registered
+			configurationName: "bar",
+			content:           `not json`,
+			wantErr:           false,
+		},
+	}
+
+	registry := NewRegistry()
+	err := registry.Register("foo", testSchema)
+	if err != nil {
+		t.Fatalf("expected nil, but received %v", err)
+	}
+
+	for i, c := range cases {
+		err := registry.Validate(c.configurationName, c.content)
+		if e, a := c.wantErr, err != nil; e != a {
+			t.Errorf("case %d, expected error %v, but received %v", i, e, err)
+		}
+	}
+}
+
+func TestRegistry_RegisterInvalid(t *testing.T) {
+	registry := NewRegistry()
+	err := registry.Register("foo", `{"type": 1}`)
+	if err == nil {
+		t.Errorf("expected error, but received nil")
+	}
+
+	if _, found := registry.Get("foo"); found {
+		t.Errorf("expected invalid schema not to be registered")
+	}
+}
+
+func TestCompileDraft4(t *testing.T) {
+	cases := []struct {
+		source  string
+		content string
+		wantErr bool
+	}{
+		{
+			source:  testSchema,
+			content: `{"name": "foo"}`,
+			wantErr: false,
+		},
+		{
+			// no $schema, compiled as draft-04: exclusiveMinimum is a boolean
+			source:  `{"type": "integer", "minimum": 1, "exclusiveMinimum": true}`,
+			content: `1`,
+			wantErr: true,
+		},
+		{
+			source:  `{"$schema": "https://json-schema.org/draft/2020-12/schema", "type": "object"}`,
+			wantErr: true,
+		},
+		{
+			source:  `{"$schema": "http://json-schema.org/draft-07/schema#", "type": "object"}`,
+			wantErr: true,
+		},
+	}
+
+	for i, c := range cases {
+		s, err := CompileDraft4("foo", c.source)
+		if err == nil {
+			err = s.Validate(c.content)
+		}
+		if e, a := c.wantErr, err != nil; e != a {
+			t.Errorf("case %d, expected error %v, but received %v", i, e, err)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package ticker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronField is the set of the allowed values of a field, bit i for value i.
+type cronField uint64
+
+func (f cronField) has(i int) bool {
+	return f&(1<<uint(i)) != 0
+}
+
+type cronBounds struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteBounds = cronBounds{name: "minute", min: 0, max: 59}
+	hourBounds   = cronBounds{name: "hour", min: 0, max: 23}
+	domBounds    = cronBounds{name: "day of month", min: 1, max: 31}
+	monthBounds  = cronBounds{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is Sunday too
+	dowBounds = cronBounds{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronSearchYears bounds the search of the next tick, a spec like "0 0 30 2 *"
+// never matches.
+const cronSearchYears = 5
+
+type cronSchedule struct {
+	minute, hour, dom, month, dow cronField
+	// domStar and dowStar tell if the day of month and the day of week are *,
+	// when neither is, a day matching either of them matches
+	domStar, dowStar bool
+	location         *time.Location
+}
+
+// ParseCron parses a standard 5 field cron spec or a descriptor like @daily,
+// in the local time zone.
+func ParseCron(spec string) (Schedule, error) {
+	return ParseCronInLocation(spec, time.Local)
+}
+
+// ParseCronInLocation is like ParseCron but evaluates the spec in location.
+func ParseCronInLocation(spec string, location *time.Location) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if descriptor, ok := cronDescriptors[strings.ToLower(spec)]; ok {
+		spec = descriptor
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron spec [%s], expected 5 fields but received %d", spec, len(fields))
+	}
+
+	s := &cronSchedule{
+		domStar:  isCronStar(fields[2]),
+		dowStar:  isCronStar(fields[4]),
+		location: location,
+	}
+	var err error
+	for i, target := range []struct {
+		field  *cronField
+		bounds cronBounds
+	}{
+		{&s.minute, minuteBounds},
+		{&s.hour, hourBounds},
+		{&s.dom, domBounds},
+		{&s.month, monthBounds},
+		{&s.dow, dowBounds},
+	} {
+		*target.field, err = parseCronField(fields[i], target.bounds)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron spec [%s], %w", spec, err)
+		}
+	}
+	if s.dow.has(7) {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+// MustParseCron is like ParseCron but panics if the spec is invalid.
+func MustParseCron(spec string) Schedule {
+	s, err := ParseCron(spec)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func isCronStar(field string) bool {
+	return field == "*" || field == "?"
+}
+
+func parseCronField(field string, bounds cronBounds) (cronField, error) {
+	var f cronField
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rangePart = part[:i]
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step [%s] of %s", part, bounds.name)
+			}
+		}
+
+		var start, end int
+		switch {
+		case isCronStar(rangePart):
+			start, end = bounds.min, bounds.max
+		case strings.Contains(rangePart, "-"):
+			i := strings.Index(rangePart, "-")
+			var err error
+			start, err = parseCronValue(rangePart[:i], bounds)
+			if err != nil {
+				return 0, err
+			}
+			end, err = parseCronValue(rangePart[i+1:], bounds)
+			if err != nil {
+				return 0, err
+			}
+		default:
+			var err error
+			start, err = parseCronValue(rangePart, bounds)
+			if err != nil {
+				return 0, err
+			}
+			end = start
+			// 5/15 is every 15 from 5
+			if strings.Contains(part, "/") {
+				end = bounds.max
+			}
+		}
+		if start > end {
+			return 0, fmt.Errorf("invalid range [%s] of %s", part, bounds.name)
+		}
+
+		for i := start; i <= end; i += step {
+			f |= 1 << uint(i)
+		}
+	}
+	return f, nil
+}
+
+func parseCronValue(value string, bounds cronBounds) (int, error) {
+	if i, ok := bounds.names[strings.ToLower(value)]; ok {
+		return i, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil || i < bounds.min || i > bounds.max {
+		return 0, fmt.Errorf("invalid value [%s] of %s, expected %d to %d", value, bounds.name, bounds.min, bounds.max)
+	}
+	return i, nil
+}
+
+// Next returns the first minute after t matching the spec.
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	t = t.In(s.location).Truncate(time.Minute).Add(time.Minute)
+	yearLimit := t.Year() + cronSearchYears
+
+	for t.Year() <= yearLimit {
+		if !s.month.has(int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.location)
+			continue
+		}
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.location)
+			continue
+		}
+		if !s.hour.has(t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.location)
+			continue
+		}
+		if !s.minute.has(t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *cronSchedule) matchDay(t time.Time) bool {
+	dom := s.dom.has(t.Day())
+	dow := s.dow.has(int(t.Weekday()))
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
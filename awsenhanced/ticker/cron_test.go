@@ -0,0 +1,127 @@
+package ticker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCron_Next(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatalf("expected nil, but received %v", err)
+	}
+
+	cases := []struct {
+		spec     string
+		location *time.Location
+		time     time.Time
+		expected time.Time
+	}{
+		{
+			spec:     "* * * * *",
+			time:     time.Date(2022, 1, 1, 0, 0, 30, 0, time.UTC),
+			expected: time.Date(2022, 1, 1, 0, 1, 0, 0, time.UTC),
+		},
+		{
+			// the next tick is strictly after the time
+			spec:     "0 * * * *",
+			time:     time.Date(2022, 1, 1, 1, 0, 0, 0, time.UTC),
+			expected: time.Date(2022, 1, 1, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			spec:     "*/20 3 * * *",
+			time:     time.Date(2022, 1, 1, 3, 45, 0, 0, time.UTC),
+			expected: time.Date(2022, 1, 2, 3, 0, 0, 0, time.UTC),
+		},
+		{
+			spec:     "5/20 3 * * *",
+			time:     time.Date(2022, 1, 1, 3, 30, 0, 0, time.UTC),
+			expected: time.Date(2022, 1, 1, 3, 45, 0, 0, time.UTC),
+		},
+		{
+			spec:     "30 1,22-23 * * *",
+			time:     time.Date(2022, 1, 1, 2, 0, 0, 0, time.UTC),
+			expected: time.Date(2022, 1, 1, 22, 30, 0, 0, time.UTC),
+		},
+		{
+			// 2022-01-01 is a Saturday
+			spec:     "0 9 * * MON-FRI",
+			time:     time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+			expected: time.Date(2022, 1, 3, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			spec:     "0 0 * * 7",
+			time:     time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+			expected: time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			// either the day of month or the day of week
+			spec:     "0 0 15 * MON",
+			time:     time.Date(2022, 1, 4, 0, 0, 0, 0, time.UTC),
+			expected: time.Date(2022, 1, 10, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			spec:     "0 0 31 * *",
+			time:     time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC),
+			expected: time.Date(2022, 3, 31, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			spec:     "0 0 29 feb *",
+			time:     time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+			expected: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			spec:     "@monthly",
+			time:     time.Date(2022, 12, 15, 0, 0, 0, 0, time.UTC),
+			expected: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			// 02:00 in Shanghai is 18:00 UTC
+			spec:     "0 2 * * *",
+			location: shanghai,
+			time:     time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC),
+			expected: time.Date(2022, 1, 1, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			spec:     "0 0 30 2 *",
+			time:     time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+			expected: time.Time{},
+		},
+	}
+
+	for i, c := range cases {
+		location := c.location
+		if location == nil {
+			location = time.UTC
+		}
+		schedule, err := ParseCronInLocation(c.spec, location)
+		if err != nil {
+			t.Errorf("case %d %s, expected nil, but received %v", i, c.spec, err)
+			continue
+		}
+		if e, a := c.expected, schedule.Next(c.time); !e.Equal(a) {
+			t.Errorf("case %d %s, expected %v, but received %v", i, c.spec, e, a)
+		}
+	}
+}
+
+func TestCron_Invalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+		"@every",
+	} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Errorf("%q, expected error, but received nil", spec)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package ticker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Schedule decides when the ticks happen.
+type Schedule interface {
+	// Next returns the time of the tick after t, the zero time when there is
+	// no more tick.
+	Next(t time.Time) time.Time
+}
+
+// FeedbackSchedule is a Schedule that adapts to the outcome of the runs, the
+// runner reports it with Fail and Succeed.
+type FeedbackSchedule interface {
+	Schedule
+	Failed()
+	Succeeded()
+}
+
+type every time.Duration
+
+// Every returns the schedule of a fixed interval, like time.Ticker. The
+// interval must be greater than zero; if not, Every will panic.
+func Every(interval time.Duration) Schedule {
+	if interval <= 0 {
+		panic("non-positive interval for ticker.Every")
+	}
+	return every(interval)
+}
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// BackoffSchedule ticks every interval while the runs succeed, after a failure
+// the interval is doubled up to the max interval, until a run succeeds.
+type BackoffSchedule struct {
+	interval    time.Duration
+	maxInterval time.Duration
+
+	lock     sync.Mutex
+	failures int
+}
+
+// NewBackoff returns a BackoffSchedule, the interval must be greater than zero
+// and not greater than the max interval; if not, NewBackoff will panic.
+func NewBackoff(interval time.Duration, maxInterval time.Duration) *BackoffSchedule {
+	if interval <= 0 {
+		panic("non-positive interval for ticker.NewBackoff")
+	}
+	if maxInterval < interval {
+		panic("max interval less than interval for ticker.NewBackoff")
+	}
+	return &BackoffSchedule{
+		interval:    interval,
+		maxInterval: maxInterval,
+	}
+}
+
+func (b *BackoffSchedule) Next(t time.Time) time.Time {
+	return t.Add(b.Interval())
+}
+
+// Interval returns the current interval, which depends on the consecutive failures.
+func (b *BackoffSchedule) Interval() time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	interval := b.interval
+	for i := 0; i < b.failures && interval < b.maxInterval; i++ {
+		interval *= 2
+	}
+	if interval > b.maxInterval {
+		interval = b.maxInterval
+	}
+	return interval
+}
+
+func (b *BackoffSchedule) Failed() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.failures++
+}
+
+func (b *BackoffSchedule) Succeeded() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.failures = 0
+}
+
+type outcomeKey struct{}
+
+// outcome is what the runner has reported through its context.
+type outcome struct {
+	lock      sync.Mutex
+	reported  bool
+	succeeded bool
+}
+
+func (o *outcome) report(succeeded bool) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.reported = true
+	o.succeeded = succeeded
+}
+
+func (o *outcome) get() (reported bool, succeeded bool) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	return o.reported, o.succeeded
+}
+
+// Fail reports that the run of ctx has failed, so that a FeedbackSchedule
+// backs off. The next tick is rescheduled once the run returns. Only the last
+// report of a run counts, and nothing happens for the other schedules.
+func Fail(ctx context.Context) {
+	if o, ok := ctx.Value(outcomeKey{}).(*outcome); ok {
+		o.report(false)
+	}
+}
+
+// Succeed reports that the run of ctx has succeeded, so that a FeedbackSchedule
+// goes back to its normal interval.
+func Succeed(ctx context.Context) {
+	if o, ok := ctx.Value(outcomeKey{}).(*outcome); ok {
+		o.report(true)
+	}
+}
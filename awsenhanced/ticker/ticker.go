@@ -1,53 +1,476 @@
 package ticker
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"math/rand"
 	"runtime/debug"
+	"sync"
 	"time"
+
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/clock"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/logger"
+)
+
+// Runner is called on every tick, ctx is cancelled when the ticker is stopped.
+type Runner func(ctx context.Context)
+
+// PanicHandler is called with the recovered value and the stack when a run
+// panics, the ticker keeps running.
+type PanicHandler func(recovered interface{}, stack []byte)
+
+// OverlapPolicy decides what happens to a tick when the previous run has not
+// returned yet.
+type OverlapPolicy int
+
+const (
+	// Skip drops the tick.
+	Skip OverlapPolicy = iota
+	// QueueOne runs once more as soon as the current run returns, the other
+	// ticks are dropped.
+	QueueOne
+	// AllowConcurrent runs in a new goroutine anyway.
+	AllowConcurrent
 )
 
-type Runner func()
+const defaultOverlapPolicy = Skip
+
+func (p OverlapPolicy) String() string {
+	switch p {
+	case Skip:
+		return "Skip"
+	case QueueOne:
+		return "QueueOne"
+	case AllowConcurrent:
+		return "AllowConcurrent"
+	}
+	return "Unknown"
+}
 
 type Ticker struct {
-	interval time.Duration
-	ticker   *time.Ticker
-	runner   Runner
+	lock sync.Mutex
+	// interval is the one of the Every schedule, 0 for the other schedules
+	interval      time.Duration
+	schedule      Schedule
+	runner        Runner
+	onPanic       PanicHandler
+	overlapPolicy OverlapPolicy
+	// timeout is the timeout of the context of a run, 0 means no timeout
+	timeout time.Duration
+	// jitter is the max random delay added to every tick
+	jitter time.Duration
+	clock  clock.Clock
+	logger logger.Holder
+
+	// cancel, done, rearm and runs are set while the ticker is started, the
+	// timer is only used by the goroutine of the loop, which rearms it when a
+	// value is sent to rearm; runs counts the runs started since Start
+	cancel context.CancelFunc
+	done   chan struct{}
+	rearm  chan struct{}
+	runs   *sync.WaitGroup
+	// next is the time of the next tick before the jitter
+	next time.Time
+
+	// runLock guards running, pending and stats
+	runLock sync.Mutex
+	running int
+	pending bool
+	stats   Stats
+}
+
+// Stats is a snapshot of the statistics of a Ticker.
+type Stats struct {
+	// LastStart and LastDuration are the ones of the last run that has returned
+	LastStart    time.Time
+	LastDuration time.Duration
+	Runs         int64
+	Panics       int64
+	// Timeouts counts the runs that have returned after their timeout
+	Timeouts int64
+	// Skipped counts the ticks dropped by the overlap policy
+	Skipped int64
+	Running int
+	// Next is the time of the next tick before the jitter, the zero time when
+	// the ticker is stopped or the schedule has no more tick
+	Next time.Time
+}
+
+type Option func(*Ticker)
+
+// WithPanicHandler replaces the default handler, which logs the panic.
+func WithPanicHandler(onPanic PanicHandler) Option {
+	return func(t *Ticker) {
+		t.onPanic = onPanic
+	}
+}
+
+// WithOverlapPolicy sets what happens to a tick when the previous run has
+// not returned yet, Skip by default.
+func WithOverlapPolicy(overlapPolicy OverlapPolicy) Option {
+	return func(t *Ticker) {
+		t.overlapPolicy = overlapPolicy
+	}
+}
+
+// WithLogger sets the logger of the ticker instead of the default logger.
+func WithLogger(l logger.Logger) Option {
+	return func(t *Ticker) {
+		t.logger.Set(l)
+	}
+}
+
+// WithClock replaces the clock of the time package, e.g. by a fake clock in tests.
+func WithClock(c clock.Clock) Option {
+	return func(t *Ticker) {
+		t.clock = c
+	}
+}
+
+// WithJitter delays every tick by a random duration up to jitter, so that the
+// processes sharing a schedule do not run at the same time. The ticks after
+// it are scheduled as if there was no jitter.
+func WithJitter(jitter time.Duration) Option {
+	return func(t *Ticker) {
+		t.jitter = jitter
+	}
 }
 
-func New(interval time.Duration, runner Runner) *Ticker {
-	return &Ticker{
-		interval: interval,
-		ticker:   time.NewTicker(interval),
-		runner:   runner,
+// WithTimeout sets the timeout of the context passed to every run. Like any
+// context deadline, it is measured by the time package whatever the clock.
+func WithTimeout(timeout time.Duration) Option {
+	return func(t *Ticker) {
+		t.timeout = timeout
 	}
 }
 
+// New returns a ticker that runs the runner every interval, it panics if the
+// interval is not greater than zero.
+func New(interval time.Duration, runner Runner, opts ...Option) *Ticker {
+	t := NewWithSchedule(Every(interval), runner, opts...)
+	t.interval = interval
+	return t
+}
+
+// NewWithSchedule returns a ticker that runs the runner at the ticks of the
+// schedule, e.g. a cron schedule returned by ParseCron or a BackoffSchedule.
+func NewWithSchedule(schedule Schedule, runner Runner, opts ...Option) *Ticker {
+	t := &Ticker{
+		schedule:      schedule,
+		runner:        runner,
+		overlapPolicy: defaultOverlapPolicy,
+		clock:         clock.New(),
+	}
+	t.onPanic = t.logPanic
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+func (t *Ticker) logPanic(recovered interface{}, stack []byte) {
+	t.logger.Get().Error("ticker runner panic", logger.Any("panic", recovered), logger.Any("stack", string(stack)))
+}
+
+// Start runs the runner at every tick in a goroutine until Stop is called,
+// it does nothing if the ticker is already started.
 func (t *Ticker) Start() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.done != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.cancel = cancel
+	t.done = make(chan struct{})
+	t.rearm = make(chan struct{}, 1)
+	// a WaitGroup per Start, Stop only waits for its runs
+	t.runs = &sync.WaitGroup{}
+	go t.loop(ctx, t.done, t.rearm, t.runs)
+}
+
+// scheduleNext sets the next tick after base and returns the delay of the
+// timer, false if there is no more tick. It must be called with the lock.
+func (t *Ticker) scheduleNext(base time.Time) (time.Duration, bool) {
+	now := t.clock.Now()
+	next := t.schedule.Next(base)
+	if !next.IsZero() && next.Before(now) {
+		// the missed ticks are dropped like time.Ticker does
+		next = t.schedule.Next(now)
+	}
+	t.next = next
+	if next.IsZero() {
+		t.logger.Get().Warn("ticker schedule has no more tick", logger.Any("after", base))
+		return 0, false
+	}
+
+	delay := next.Sub(now)
+	if t.jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(t.jitter)))
+	}
+	return delay, true
+}
+
+func (t *Ticker) loop(ctx context.Context, done chan struct{}, rearm chan struct{}, runs *sync.WaitGroup) {
+	defer close(done)
+
+	var timer clock.Timer
+	var ticks <-chan time.Time
+	// arm sets the timer for the next tick after base, ticks is nil when there is none
+	arm := func(base func() time.Time) {
+		t.lock.Lock()
+		if ctx.Err() != nil {
+			t.lock.Unlock()
+			return
+		}
+		delay, ok := t.scheduleNext(base())
+		t.lock.Unlock()
+
+		if timer != nil && !timer.Stop() {
+			select {
+			case <-timer.C():
+			default:
+			}
+		}
+		ticks = nil
+		if !ok {
+			return
+		}
+		if timer == nil {
+			timer = t.clock.NewTimer(delay)
+		} else {
+			timer.Reset(delay)
+		}
+		ticks = timer.C()
+	}
+	arm(t.clock.Now)
+
+	for {
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-ticks:
+			t.tick(ctx, runs)
+			arm(func() time.Time { return t.next })
+		case <-rearm:
+			// the schedule has changed, the next tick is the one after now
+			arm(t.clock.Now)
+		}
+	}
+}
+
+// rearmLocked makes the loop reschedule the next tick, it must be called with the lock.
+func (t *Ticker) rearmLocked() {
+	if t.rearm == nil {
+		return
+	}
+	select {
+	case t.rearm <- struct{}{}:
+	default:
+	}
+}
+
+// tick starts a run in a goroutine unless the overlap policy drops or queues it.
+func (t *Ticker) tick(ctx context.Context, runs *sync.WaitGroup) {
+	t.runLock.Lock()
+	defer t.runLock.Unlock()
+
+	if t.running > 0 {
+		switch t.overlapPolicy {
+		case QueueOne:
+			if !t.pending {
+				t.pending = true
+				return
+			}
+			t.stats.Skipped++
+			return
+		case AllowConcurrent:
+		default:
+			t.stats.Skipped++
+			return
+		}
+	}
+
+	t.running++
+	runs.Add(1)
 	go func() {
-		defer func() {
-			if e := recover(); e != nil {
-				stack := string(debug.Stack())
-				fmt.Println(stack)
-				fmt.Println(e)
+		defer runs.Done()
+
+		for {
+			t.run(ctx)
+
+			t.runLock.Lock()
+			if t.pending && ctx.Err() == nil {
+				// run the queued tick
+				t.pending = false
+				t.runLock.Unlock()
+				continue
 			}
-		}()
-		for range t.ticker.C {
-			t.runner()
+			t.pending = false
+			t.running--
+			t.runLock.Unlock()
+			return
+		}
+	}()
+}
+
+// run calls the runner once, a panic is recovered so that the next ticks still run.
+func (t *Ticker) run(ctx context.Context) {
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+
+	o := &outcome{}
+	ctx = context.WithValue(ctx, outcomeKey{}, o)
+
+	startTime := t.clock.Now()
+	panicked := false
+	defer t.feedback(o)
+	defer func() {
+		t.runLock.Lock()
+		t.stats.LastStart = startTime
+		t.stats.LastDuration = t.clock.Since(startTime)
+		t.stats.Runs++
+		if panicked {
+			t.stats.Panics++
 		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			t.stats.Timeouts++
+		}
+		t.runLock.Unlock()
 	}()
+	defer func() {
+		if e := recover(); e != nil {
+			panicked = true
+			t.onPanic(e, debug.Stack())
+		}
+	}()
+
+	t.runner(ctx)
+}
+
+// feedback passes the outcome reported by the runner to a FeedbackSchedule and
+// reschedules the next tick after it.
+func (t *Ticker) feedback(o *outcome) {
+	reported, succeeded := o.get()
+	if !reported {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	schedule, ok := t.schedule.(FeedbackSchedule)
+	if !ok {
+		return
+	}
+	if succeeded {
+		schedule.Succeeded()
+	} else {
+		schedule.Failed()
+	}
+	t.rearmLocked()
+}
+
+// Stats returns the statistics of the runs since the ticker has been created.
+func (t *Ticker) Stats() Stats {
+	t.runLock.Lock()
+
+	stats := t.stats
+	stats.Running = t.running
+	t.runLock.Unlock()
+
+	t.lock.Lock()
+	if t.done != nil {
+		stats.Next = t.next
+	}
+	t.lock.Unlock()
+	return stats
 }
 
+// Stop cancels the context of the current runs, waits for them to return and
+// stops the goroutine. The ticker can be started again. Stop must not be
+// called by the runner, which would wait for itself.
 func (t *Ticker) Stop() {
-	t.ticker.Stop()
+	t.lock.Lock()
+	if t.done == nil {
+		t.lock.Unlock()
+		return
+	}
+
+	t.cancel()
+	done, runs := t.done, t.runs
+	t.cancel = nil
+	t.done = nil
+	t.rearm = nil
+	t.runs = nil
+	t.next = time.Time{}
+	t.lock.Unlock()
+
+	// wait once no tick can Add anymore
+	<-done
+	runs.Wait()
 }
 
+// Reset replaces the schedule by Every(d) and returns the old interval, 0 if
+// the old schedule was not an Every schedule. The next tick is d after now.
+// It panics if d is not greater than zero.
 func (t *Ticker) Reset(d time.Duration) time.Duration {
+	schedule := Every(d)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
 	oldInterval := t.interval
 	t.interval = d
-	t.ticker.Reset(d)
+	t.schedule = schedule
+	t.rearmLocked()
 	return oldInterval
 }
 
+// SetSchedule replaces the schedule, the next tick is the one after now.
+func (t *Ticker) SetSchedule(schedule Schedule) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.interval = 0
+	t.schedule = schedule
+	t.rearmLocked()
+}
+
+// SetJitter replaces the max random delay of the ticks, it applies from the
+// tick after the next one.
+func (t *Ticker) SetJitter(jitter time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.jitter = jitter
+}
+
+// Jitter returns the max random delay of the ticks.
+func (t *Ticker) Jitter() time.Duration {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.jitter
+}
+
+// SetLogger replaces the logger of the ticker, nil means the default logger.
+func (t *Ticker) SetLogger(l logger.Logger) {
+	t.logger.Set(l)
+}
+
+// Interval returns the interval of the Every schedule, 0 for the other schedules.
 func (t *Ticker) Interval() time.Duration {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
 	return t.interval
 }
@@ -0,0 +1,411 @@
+package ticker
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/clock/fakeclock"
+)
+
+func TestTicker_Run(t *testing.T) {
+	var runs int64
+	ticker := New(time.Millisecond*10, func(ctx context.Context) {
+		atomic.AddInt64(&runs, 1)
+	})
+	ticker.Start()
+	// Start is idempotent, a second goroutine would double the runs
+	ticker.Start()
+	time.Sleep(time.Millisecond * 55)
+	ticker.Stop()
+
+	stopped := atomic.LoadInt64(&runs)
+	if stopped < 3 || stopped > 6 {
+		t.Errorf("expected about 5 runs, but received %v", stopped)
+	}
+
+	time.Sleep(time.Millisecond * 30)
+	if e, a := stopped, atomic.LoadInt64(&runs); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	// it can be started again
+	ticker.Start()
+	time.Sleep(time.Millisecond * 25)
+	ticker.Stop()
+	if a := atomic.LoadInt64(&runs); a <= stopped {
+		t.Errorf("expected more than %v runs, but received %v", stopped, a)
+	}
+	ticker.Stop()
+}
+
+func TestTicker_Panic(t *testing.T) {
+	var runs, panics int64
+	ticker := New(time.Millisecond*10, func(ctx context.Context) {
+		if atomic.AddInt64(&runs, 1)%2 == 1 {
+			panic("runner panic")
+		}
+	}, WithPanicHandler(func(recovered interface{}, stack []byte) {
+		if e, a := "runner panic", recovered; e != a {
+			t.Errorf("expected %v, but received %v", e, a)
+		}
+		if len(stack) == 0 {
+			t.Errorf("expected the stack, but received nothing")
+		}
+		atomic.AddInt64(&panics, 1)
+	}))
+	ticker.Start()
+	time.Sleep(time.Millisecond * 55)
+	ticker.Stop()
+
+	// the runs after a panic still happen
+	if a := atomic.LoadInt64(&runs); a < 3 {
+		t.Errorf("expected at least 3 runs, but received %v", a)
+	}
+	if e, a := (atomic.LoadInt64(&runs)+1)/2, atomic.LoadInt64(&panics); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestTicker_StopWaitsForRun(t *testing.T) {
+	started := make(chan struct{})
+	var finished int64
+	ticker := New(time.Millisecond*5, func(ctx context.Context) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-ctx.Done()
+		time.Sleep(time.Millisecond * 20)
+		atomic.StoreInt64(&finished, 1)
+	})
+	ticker.Start()
+	<-started
+
+	ticker.Stop()
+	if e, a := int64(1), atomic.LoadInt64(&finished); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestTicker_Restart(t *testing.T) {
+	var running, finished int64
+	ticker := New(time.Millisecond, func(ctx context.Context) {
+		atomic.AddInt64(&running, 1)
+		<-ctx.Done()
+		atomic.AddInt64(&running, -1)
+		atomic.AddInt64(&finished, 1)
+	}, WithOverlapPolicy(AllowConcurrent))
+
+	// every Stop waits for the runs of its Start only
+	for i := 0; i < 20; i++ {
+		ticker.Start()
+		time.Sleep(time.Millisecond * 3)
+		ticker.Stop()
+		if a := atomic.LoadInt64(&running); a != 0 {
+			t.Fatalf("expected no run after Stop, but received %v", a)
+		}
+	}
+	if a := atomic.LoadInt64(&finished); a == 0 {
+		t.Errorf("expected runs, but received none")
+	}
+}
+
+func TestTicker_Reset(t *testing.T) {
+	ticker := New(time.Hour, func(ctx context.Context) {})
+	if e, a := time.Hour, ticker.Reset(time.Minute); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	ticker.Start()
+	defer ticker.Stop()
+	if e, a := time.Minute, ticker.Reset(time.Second); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := time.Second, ticker.Interval(); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+// advanceUntil moves the clock an interval at a time until the ticks it fires
+// have been handled, the ticks the loop is too slow for are dropped.
+func advanceUntil(t *testing.T, clk *fakeclock.Clock, interval time.Duration, done func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the ticks to be handled")
+		}
+		clk.Advance(interval)
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waitForNext waits for the loop to schedule the next tick at next.
+func waitForNext(t *testing.T, ticker *Ticker, next time.Time) {
+	deadline := time.Now().Add(time.Second)
+	for !ticker.Stats().Next.Equal(next) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the next tick at %v, but received %v", next, ticker.Stats().Next)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTicker_OverlapPolicy(t *testing.T) {
+	cases := []struct {
+		overlapPolicy OverlapPolicy
+		// concurrent is the number of runs started by the ticks before the first run returns
+		concurrent int
+		skipped    int64
+		runs       int64
+	}{
+		{overlapPolicy: Skip, concurrent: 1, skipped: 2, runs: 1},
+		// the queued tick runs once the first run returns
+		{overlapPolicy: QueueOne, concurrent: 1, skipped: 2, runs: 2},
+		{overlapPolicy: AllowConcurrent, concurrent: 3, skipped: 0, runs: 3},
+	}
+
+	for _, c := range cases {
+		clk := fakeclock.New(time.Now())
+		started := make(chan struct{}, 10)
+		release := make(chan struct{})
+		ticker := New(time.Second, func(ctx context.Context) {
+			started <- struct{}{}
+			<-release
+		}, WithOverlapPolicy(c.overlapPolicy), WithClock(clk))
+		ticker.Start()
+		clk.WaitForTimers(1)
+
+		if c.overlapPolicy == AllowConcurrent {
+			advanceUntil(t, clk, time.Second, func() bool { return len(started) == c.concurrent })
+		} else {
+			advanceUntil(t, clk, time.Second, func() bool { return len(started) == 1 })
+			advanceUntil(t, clk, time.Second, func() bool { return ticker.Stats().Skipped >= c.skipped })
+		}
+		if e, a := c.concurrent, ticker.Stats().Running; e != a {
+			t.Errorf("%v, expected %v, but received %v", c.overlapPolicy, e, a)
+		}
+
+		close(release)
+		advanceUntil(t, clk, 0, func() bool { return ticker.Stats().Runs == c.runs })
+		ticker.Stop()
+
+		stats := ticker.Stats()
+		if e, a := c.runs, stats.Runs; e != a {
+			t.Errorf("%v, expected %v, but received %v", c.overlapPolicy, e, a)
+		}
+		if e, a := c.skipped, stats.Skipped; e != a {
+			t.Errorf("%v, expected %v, but received %v", c.overlapPolicy, e, a)
+		}
+		if e, a := 0, stats.Running; e != a {
+			t.Errorf("%v, expected %v, but received %v", c.overlapPolicy, e, a)
+		}
+	}
+}
+
+func TestTicker_Stats(t *testing.T) {
+	var runs int64
+	ticker := New(time.Millisecond*10, func(ctx context.Context) {
+		switch atomic.AddInt64(&runs, 1) {
+		case 1:
+			panic("runner panic")
+		case 2:
+			<-ctx.Done()
+		}
+	}, WithTimeout(time.Millisecond*5), WithPanicHandler(func(interface{}, []byte) {}))
+
+	startTime := time.Now()
+	ticker.Start()
+	time.Sleep(time.Millisecond * 45)
+	ticker.Stop()
+
+	stats := ticker.Stats()
+	if stats.Runs < 3 {
+		t.Errorf("expected at least 3 runs, but received %v", stats.Runs)
+	}
+	if e, a := int64(1), stats.Panics; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := int64(1), stats.Timeouts; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if stats.LastStart.Before(startTime) || stats.LastDuration <= 0 {
+		t.Errorf("expected the last run, but received %v, %v", stats.LastStart, stats.LastDuration)
+	}
+}
+
+func TestTicker_Clock(t *testing.T) {
+	clk := fakeclock.New(time.Now())
+	runs := make(chan time.Time)
+	ticker := New(time.Minute, func(ctx context.Context) {
+		runs <- clk.Now()
+	}, WithClock(clk))
+	ticker.Start()
+	defer ticker.Stop()
+	clk.WaitForTimers(1)
+
+	startTime := clk.Now()
+	clk.Advance(time.Second * 59)
+	select {
+	case <-runs:
+		t.Errorf("expected no run before the interval")
+	case <-time.After(time.Millisecond * 20):
+	}
+
+	clk.Advance(time.Second)
+	if e, a := startTime.Add(time.Minute), <-runs; !e.Equal(a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	// the next tick is an interval after the reset
+	ticker.Reset(time.Hour)
+	waitForNext(t, ticker, startTime.Add(time.Minute+time.Hour))
+	clk.Advance(time.Hour)
+	if e, a := startTime.Add(time.Minute+time.Hour), <-runs; !e.Equal(a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestTicker_Schedule(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 30, 0, time.UTC)
+	clk := fakeclock.New(start)
+	schedule, err := ParseCronInLocation("*/15 2-3 * * *", time.UTC)
+	if err != nil {
+		t.Fatalf("expected nil, but received %v", err)
+	}
+
+	runs := make(chan time.Time)
+	ticker := NewWithSchedule(schedule, func(ctx context.Context) {
+		runs <- clk.Now()
+	}, WithClock(clk))
+	ticker.Start()
+	defer ticker.Stop()
+	if e, a := time.Duration(0), ticker.Interval(); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	for _, expected := range []time.Time{
+		time.Date(2022, 1, 1, 2, 0, 0, 0, time.UTC),
+		time.Date(2022, 1, 1, 2, 15, 0, 0, time.UTC),
+		time.Date(2022, 1, 1, 3, 45, 0, 0, time.UTC),
+		time.Date(2022, 1, 2, 2, 0, 0, 0, time.UTC),
+	} {
+		waitForNext(t, ticker, expected)
+		clk.WaitForTimers(1)
+		clk.Set(expected)
+		if e, a := expected, <-runs; !e.Equal(a) {
+			t.Errorf("expected %v, but received %v", e, a)
+		}
+		// like time.Ticker, a late tick runs once and the ticks missed since are dropped
+		if expected.Minute() == 15 {
+			waitForNext(t, ticker, expected.Add(time.Minute*15))
+			clk.WaitForTimers(1)
+			late := time.Date(2022, 1, 1, 3, 40, 0, 0, time.UTC)
+			clk.Set(late)
+			if e, a := late, <-runs; !e.Equal(a) {
+				t.Errorf("expected %v, but received %v", e, a)
+			}
+		}
+	}
+}
+
+func TestTicker_Jitter(t *testing.T) {
+	clk := fakeclock.New(time.Now())
+	runs := make(chan time.Time)
+	ticker := New(time.Minute, func(ctx context.Context) {
+		runs <- clk.Now()
+	}, WithClock(clk), WithJitter(time.Second*10))
+	ticker.Start()
+	defer ticker.Stop()
+
+	startTime := clk.Now()
+	for i := 1; i <= 5; i++ {
+		// the jitter does not drift the schedule
+		tick := startTime.Add(time.Minute * time.Duration(i))
+		waitForNext(t, ticker, tick)
+		clk.WaitForTimers(1)
+		clk.Set(tick.Add(time.Second * 10))
+		if a := <-runs; a.Before(tick) || a.After(tick.Add(time.Second*10)) {
+			t.Errorf("expected %v to %v, but received %v", tick, tick.Add(time.Second*10), a)
+		}
+	}
+
+	// the jitter changes in place from the tick after the next one
+	ticker.SetJitter(0)
+	tick := startTime.Add(time.Minute * 6)
+	waitForNext(t, ticker, tick)
+	clk.WaitForTimers(1)
+	clk.Set(tick.Add(time.Second * 10))
+	<-runs
+	tick = startTime.Add(time.Minute * 7)
+	waitForNext(t, ticker, tick)
+	clk.WaitForTimers(1)
+	clk.Set(tick)
+	if e, a := tick, <-runs; !e.Equal(a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := time.Duration(0), ticker.Jitter(); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestTicker_Backoff(t *testing.T) {
+	clk := fakeclock.New(time.Now())
+	backoff := NewBackoff(time.Minute, time.Minute*5)
+	var fail int64 = 1
+	runs := make(chan time.Time)
+	ticker := NewWithSchedule(backoff, func(ctx context.Context) {
+		if atomic.LoadInt64(&fail) == 1 {
+			Fail(ctx)
+		} else {
+			Succeed(ctx)
+		}
+		runs <- clk.Now()
+	}, WithClock(clk))
+	ticker.Start()
+	defer ticker.Stop()
+
+	next := clk.Now()
+	for i, interval := range []time.Duration{
+		// the interval doubles after every failure up to the max interval
+		time.Minute, time.Minute * 2, time.Minute * 4, time.Minute * 5,
+		// and is reset by a success
+		time.Minute,
+	} {
+		if i == 3 {
+			atomic.StoreInt64(&fail, 0)
+		}
+		next = next.Add(interval)
+		waitForNext(t, ticker, next)
+		clk.WaitForTimers(1)
+		clk.Set(next)
+		<-runs
+	}
+	if e, a := time.Minute, backoff.Interval(); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestTicker_InvalidInterval(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{name: "every zero", fn: func() { Every(0) }},
+		{name: "every negative", fn: func() { Every(-time.Second) }},
+		{name: "new zero", fn: func() { New(0, func(ctx context.Context) {}) }},
+		{name: "reset zero", fn: func() { New(time.Second, func(ctx context.Context) {}).Reset(0) }},
+		{name: "backoff zero", fn: func() { NewBackoff(0, time.Minute) }},
+		{name: "backoff max less than interval", fn: func() { NewBackoff(time.Minute, time.Second) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("expected panic, but received %v", r)
+				}
+			}()
+			tt.fn()
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// Package oteltracing traces with an OpenTelemetry tracer.
+package oteltracing
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestSpanKey carries the span of a request of an AWS client, which is not
+// the span of the context given to the client.
+type requestSpanKey struct{}
+
+type tracer struct {
+	tracer trace.Tracer
+}
+
+// New adapts a tracer, e.g. otel.Tracer("appconfig").
+func New(t trace.Tracer) tracing.Tracer {
+	return &tracer{tracer: t}
+}
+
+func attributes(attrs []tracing.Attribute) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		kvs = append(kvs, attribute.String(attr.Key, attr.Value))
+	}
+	return kvs
+}
+
+func (t *tracer) Start(ctx context.Context, name string, attrs ...tracing.Attribute) (context.Context, tracing.Span) {
+	ctx, s := t.tracer.Start(ctx, name, trace.WithAttributes(attributes(attrs)...))
+	return ctx, span{span: s}
+}
+
+// InstrumentClient starts a client span for each request of the client, from
+// the build of the request to its completion, so the retries are in one span.
+func (t *tracer) InstrumentClient(c *client.Client) {
+	c.Handlers.Build.PushFrontNamed(request.NamedHandler{
+		Name: "oteltracing.Start",
+		Fn: func(r *request.Request) {
+			ctx, s := t.tracer.Start(r.Context(), r.ClientInfo.ServiceName+"."+r.Operation.Name,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					attribute.String("rpc.system", "aws-api"),
+					attribute.String("rpc.service", r.ClientInfo.ServiceName),
+					attribute.String("rpc.method", r.Operation.Name),
+				),
+			)
+			r.SetContext(context.WithValue(ctx, requestSpanKey{}, s))
+		},
+	})
+	c.Handlers.Complete.PushBackNamed(request.NamedHandler{
+		Name: "oteltracing.End",
+		Fn: func(r *request.Request) {
+			// a request failing validation is not built, so it has no span
+			s, ok := r.Context().Value(requestSpanKey{}).(trace.Span)
+			if !ok {
+				return
+			}
+			if r.HTTPResponse != nil {
+				s.SetAttributes(attribute.Int("http.status_code", r.HTTPResponse.StatusCode))
+			}
+			if r.RequestID != "" {
+				s.SetAttributes(attribute.String("aws.request_id", r.RequestID))
+			}
+			span{span: s}.End(r.Error)
+		},
+	})
+}
+
+func (t *tracer) TraceID(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasTraceID() {
+		return ""
+	}
+	return spanContext.TraceID().String()
+}
+
+type span struct {
+	span trace.Span
+}
+
+func (s span) SetAttributes(attrs ...tracing.Attribute) {
+	s.span.SetAttributes(attributes(attrs)...)
+}
+
+func (s span) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
@@ -0,0 +1,125 @@
+package oteltracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/appconfig"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newTracer() (tracing.Tracer, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	return New(provider.Tracer("test")), recorder
+}
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[string]string {
+	attrs := map[string]string{}
+	for _, kv := range span.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	return attrs
+}
+
+func TestTracer_Start(t *testing.T) {
+	tracer, recorder := newTracer()
+
+	ctx, parent := tracer.Start(context.Background(), "parent", tracing.Configuration("a"))
+	parentTraceID := tracer.TraceID(ctx)
+	_, child := tracer.Start(ctx, "child")
+	child.SetAttributes(tracing.Version("2"))
+	child.End(errors.New("failed"))
+	parent.End(nil)
+
+	if tracer.TraceID(context.Background()) != "" {
+		t.Errorf("expected no trace id, but received %v", tracer.TraceID(context.Background()))
+	}
+	spans := recorder.Ended()
+	if e, a := 2, len(spans); e != a {
+		t.Fatalf("expected %v, but received %v", e, a)
+	}
+	if e, a := parentTraceID, spans[0].SpanContext().TraceID().String(); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID(); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := map[string]string{tracing.VersionKey: "2"}, spanAttributes(spans[0]); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := codes.Error, spans[0].Status().Code; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := map[string]string{tracing.ConfigurationKey: "a"}, spanAttributes(spans[1]); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := codes.Unset, spans[1].Status().Code; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestTracer_InstrumentClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Configuration-Version", "1")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	tracer, recorder := newTracer()
+	client := appconfig.New(session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String(server.URL),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	})))
+	tracer.InstrumentClient(client.Client)
+
+	ctx, parent := tracer.Start(context.Background(), "parent")
+	_, err := client.GetConfigurationWithContext(ctx, &appconfig.GetConfigurationInput{
+		Application:   aws.String("app"),
+		Environment:   aws.String("test"),
+		Configuration: aws.String("a"),
+		ClientId:      aws.String("client"),
+	})
+	if err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+	// the invalid requests are not sent and have no span, the parent is not ended
+	_, err = client.GetConfigurationWithContext(ctx, &appconfig.GetConfigurationInput{})
+	if err == nil {
+		t.Fatalf("expected an error, but received nil")
+	}
+	if e, a := 1, len(recorder.Ended()); e != a {
+		t.Fatalf("expected %v, but received %v", e, a)
+	}
+	parent.End(nil)
+
+	spans := recorder.Ended()
+	if e, a := 2, len(spans); e != a {
+		t.Fatalf("expected %v, but received %v", e, a)
+	}
+	if e, a := "AppConfig.GetConfiguration", spans[0].Name(); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID(); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	attrs := spanAttributes(spans[0])
+	if e, a := attribute.IntValue(http.StatusOK).Emit(), attrs["http.status_code"]; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := "GetConfiguration", attrs["rpc.method"]; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
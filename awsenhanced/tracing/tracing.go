@@ -0,0 +1,87 @@
+// Package tracing is what the library traces its calls with, the X-Ray and
+// OpenTelemetry implementations are in the xraytracing and oteltracing
+// packages.
+package tracing
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws/client"
+)
+
+// the keys of the attributes of the spans
+const (
+	ApplicationKey   = "application"
+	EnvironmentKey   = "environment"
+	ConfigurationKey = "configuration"
+	VersionKey       = "version"
+)
+
+// Tracer starts the spans of the calls and traces the requests of the AWS
+// clients.
+type Tracer interface {
+	// Start starts a span, which is the child of the span of ctx if there is
+	// one. The returned context carries the span.
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+	// InstrumentClient traces the requests of an AWS client made with the
+	// context of a span.
+	InstrumentClient(c *client.Client)
+	// TraceID returns the id of the trace of ctx, "" if it is not traced.
+	TraceID(ctx context.Context) string
+}
+
+// Span is a traced call.
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	// End ends the span, which has failed if err is not nil.
+	End(err error)
+}
+
+// Attribute describes a span, e.g. the configuration of a fetch.
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+func String(key string, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Application(application string) Attribute {
+	return String(ApplicationKey, application)
+}
+
+func Environment(environment string) Attribute {
+	return String(EnvironmentKey, environment)
+}
+
+func Configuration(configuration string) Attribute {
+	return String(ConfigurationKey, configuration)
+}
+
+func Version(version string) Attribute {
+	return String(VersionKey, version)
+}
+
+type nopTracer struct{}
+
+type nopSpan struct{}
+
+// Nop returns a Tracer which traces nothing, it is used when tracing is off.
+func Nop() Tracer {
+	return nopTracer{}
+}
+
+func (nopTracer) Start(ctx context.Context, _ string, _ ...Attribute) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+func (nopTracer) InstrumentClient(*client.Client) {}
+
+func (nopTracer) TraceID(context.Context) string {
+	return ""
+}
+
+func (nopSpan) SetAttributes(...Attribute) {}
+
+func (nopSpan) End(error) {}
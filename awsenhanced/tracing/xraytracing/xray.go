@@ -0,0 +1,61 @@
+// Package xraytracing traces with AWS X-Ray.
+package xraytracing
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/tracing"
+)
+
+type tracer struct{}
+
+// New returns a Tracer starting a segment, or a subsegment when ctx already
+// carries one, for each span. The attributes are added as annotations.
+func New() tracing.Tracer {
+	return tracer{}
+}
+
+func (tracer) Start(ctx context.Context, name string, attrs ...tracing.Attribute) (context.Context, tracing.Span) {
+	var segment *xray.Segment
+	if xray.GetSegment(ctx) == nil {
+		ctx, segment = xray.BeginSegment(ctx, name)
+	} else {
+		ctx, segment = xray.BeginSubsegment(ctx, name)
+	}
+	s := span{segment: segment}
+	s.SetAttributes(attrs...)
+	return ctx, s
+}
+
+func (tracer) InstrumentClient(c *client.Client) {
+	xray.AWS(c)
+}
+
+// TraceID returns the trace id of the segment of ctx, xray.TraceID returns ""
+// for a subsegment.
+func (tracer) TraceID(ctx context.Context) string {
+	segment := xray.GetSegment(ctx)
+	if segment == nil {
+		return ""
+	}
+	if segment.ParentSegment != nil {
+		return segment.ParentSegment.TraceID
+	}
+	return segment.TraceID
+}
+
+type span struct {
+	segment *xray.Segment
+}
+
+func (s span) SetAttributes(attrs ...tracing.Attribute) {
+	for _, attr := range attrs {
+		_ = s.segment.AddAnnotation(attr.Key, attr.Value)
+	}
+}
+
+func (s span) End(err error) {
+	s.segment.Close(err)
+}
@@ -0,0 +1,50 @@
+package xraytracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-xray-sdk-go/strategy/sampling"
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/tracing"
+)
+
+func TestTracer_Start(t *testing.T) {
+	tracer := New()
+	// the default centralized sampling polls the X-Ray daemon in the background
+	strategy, err := sampling.NewLocalizedStrategy()
+	if err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+	ctx, err := xray.ContextWithConfig(context.Background(), xray.Config{SamplingStrategy: strategy})
+	if err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	ctx, parent := tracer.Start(ctx, "parent", tracing.Configuration("a"))
+	segment := xray.GetSegment(ctx)
+	childCtx, child := tracer.Start(ctx, "child", tracing.Version("2"))
+	subsegment := xray.GetSegment(childCtx)
+
+	if e, a := "", tracer.TraceID(context.Background()); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := segment.TraceID, tracer.TraceID(childCtx); e == "" || e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := segment, subsegment.ParentSegment; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := "a", segment.Annotations[tracing.ConfigurationKey]; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+	if e, a := "2", subsegment.Annotations[tracing.VersionKey]; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	child.End(nil)
+	parent.End(nil)
+	if segment.InProgress || subsegment.InProgress {
+		t.Errorf("expected the segments closed")
+	}
+}
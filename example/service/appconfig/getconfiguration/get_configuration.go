@@ -47,7 +47,7 @@ func createConfiguration(ctx context.Context, configurationName string) {
 		panic(err)
 	}
 
-	logger.Info("CreateConfiguration ", configurationName, " ", ok)
+	logger.Info("CreateConfiguration", logger.Configuration(configurationName), logger.Any("ok", ok))
 }
 
 func getConfiguration(ctx context.Context, configurationName string) {
@@ -62,9 +62,9 @@ func getConfiguration(ctx context.Context, configurationName string) {
 
 	content, err := appConfig.GetConfiguration(ctx, configurationName)
 	if err != nil {
-		logger.Error(err)
+		logger.Error("GetConfiguration fail", logger.Configuration(configurationName), logger.Err(err))
 	} else {
-		logger.Info(configurationName, ": ", content)
+		logger.Info("GetConfiguration", logger.Configuration(configurationName), logger.Any("content", content))
 	}
 }
 
@@ -79,13 +79,13 @@ func deleteConfiguration(ctx context.Context, configurationName string) {
 		panic(err)
 	}
 
-	logger.Info("DeleteConfiguration ", configurationName, " ", ok)
+	logger.Info("DeleteConfiguration", logger.Configuration(configurationName), logger.Any("ok", ok))
 }
 
 func setEnv(key, value string) bool {
 	err := os.Setenv(key, value)
 	if err != nil {
-		logger.Error(err)
+		logger.Error("set env fail", logger.Any("key", key), logger.Err(err))
 		return true
 	}
 	return false
@@ -12,11 +12,14 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/appconfig"
-	"github.com/aws/aws-xray-sdk-go/xray"
 	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/constant"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/logger"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/metrics"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/schema"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/tracing"
 )
 
-// AllAtOnceNotBake deployment strategy must have been created
+// 必须先创建 AllAtOnceNotBake 部署策略
 const deploymentStrategyName = "AllAtOnceNotBake"
 
 type EnhancedAppConfigAdvance struct {
@@ -27,7 +30,17 @@ type EnhancedAppConfigAdvance struct {
 	environmentId   string
 	appConfigClient *appconfig.AppConfig
 
-	isXRayEnable bool
+	tracer tracing.Tracer // 默认不追踪，WithXRayEnable 使用 X-Ray
+
+	logger logger.Holder // 为空时使用 logger.Default()
+
+	deploymentsStarted metrics.Counter
+	deploymentsFailed  metrics.Counter
+
+	// 发布的配置必须匹配的 JSON schema
+	schemas *schema.Registry
+	// configurationName -> 设置为 profile validator 的 *schema.Schema
+	attachedSchemas sync.Map
 }
 
 var applicationNameId = map[string]string{}
@@ -49,7 +62,10 @@ func NewWithOptions(opts ...Option) (*EnhancedAppConfigAdvance, error) {
 		applicationId:   "",
 		environmentId:   "",
 		appConfigClient: nil,
+		schemas:         schema.NewDraft4Registry(), // AppConfig 的 JSON_SCHEMA validator 只支持 draft-04
+		tracer:          tracing.Nop(),
 	}
+	appConfigAdvance.setMetrics(metrics.Nop())
 
 	err := appConfigAdvance.ApplyOptions(opts...)
 	if err != nil {
@@ -77,15 +93,11 @@ func NewWithOptions(opts ...Option) (*EnhancedAppConfigAdvance, error) {
 		}
 	}
 
-	var ctx context.Context
-	if appConfigAdvance.isXRayEnable {
-		_ctx, segment := xray.BeginSegment(context.Background(), "EnhancedAppConfigAdvance-NewWithOptions")
-		defer segment.Close(nil)
-
-		ctx = _ctx
-	} else {
-		ctx = context.Background()
-	}
+	ctx, span := appConfigAdvance.tracer.Start(context.Background(), "EnhancedAppConfigAdvance-NewWithOptions",
+		tracing.Application(appConfigAdvance.applicationName), tracing.Environment(appConfigAdvance.environmentName))
+	defer func() {
+		span.End(err)
+	}()
 
 	err = appConfigAdvance.listApplications(ctx)
 	if err != nil {
@@ -110,6 +122,13 @@ func NewWithOptions(opts ...Option) (*EnhancedAppConfigAdvance, error) {
 	return appConfigAdvance, err
 }
 
+// setMetrics 创建客户端的指标
+func (appConfigAdvance *EnhancedAppConfigAdvance) setMetrics(provider metrics.Provider) {
+	labels := []string{"application", "environment", "configuration"}
+	appConfigAdvance.deploymentsStarted = provider.Counter("appconfig_deployments_started_total", "The number of the deployments started.", labels...)
+	appConfigAdvance.deploymentsFailed = provider.Counter("appconfig_deployments_failed_total", "The number of the deployments which could not be started.", labels...)
+}
+
 func (appConfigAdvance *EnhancedAppConfigAdvance) initAppConfigClient() error {
 	awsConfig := aws.Config{
 		Region: aws.String(appConfigAdvance.regionName),
@@ -125,15 +144,50 @@ func (appConfigAdvance *EnhancedAppConfigAdvance) initAppConfigClient() error {
 		return errors.New("can not init aws AppConfig client")
 	}
 
-	if appConfigAdvance.isXRayEnable {
-		xray.AWS(appConfigClient.Client)
-	}
+	appConfigAdvance.tracer.InstrumentClient(appConfigClient.Client)
 
 	appConfigAdvance.appConfigClient = appConfigClient
 	return nil
 }
 
-func (appConfigAdvance *EnhancedAppConfigAdvance) UpdateConfiguration(ctx context.Context, configurationName string, content string) (bool, error) {
+// logCtx 返回 ctx 中的 logger
+func (appConfigAdvance *EnhancedAppConfigAdvance) logCtx(ctx context.Context) logger.Logger {
+	fields := []logger.Field{
+		logger.Application(appConfigAdvance.applicationName),
+		logger.Environment(appConfigAdvance.environmentName),
+	}
+	if traceID := appConfigAdvance.tracer.TraceID(ctx); traceID != "" {
+		fields = append(fields, logger.TraceID(traceID))
+	}
+	return logger.FromContext(ctx, appConfigAdvance.logger.Load()).With(fields...)
+}
+
+// startSpan 开始一个配置操作的 span
+func (appConfigAdvance *EnhancedAppConfigAdvance) startSpan(ctx context.Context, name string, configurationName string) (context.Context, tracing.Span) {
+	return appConfigAdvance.tracer.Start(ctx, name,
+		tracing.Application(appConfigAdvance.applicationName),
+		tracing.Environment(appConfigAdvance.environmentName),
+		tracing.Configuration(configurationName),
+	)
+}
+
+// RegisterSchema 注册配置的 draft-04 JSON schema，不匹配的内容会被拒绝，
+// 并设置为配置 profile 的 JSON_SCHEMA validator
+func (appConfigAdvance *EnhancedAppConfigAdvance) RegisterSchema(configurationName string, jsonSchema string) error {
+	return appConfigAdvance.schemas.Register(configurationName, jsonSchema)
+}
+
+func (appConfigAdvance *EnhancedAppConfigAdvance) UpdateConfiguration(ctx context.Context, configurationName string, content string) (_ bool, err error) {
+	ctx, span := appConfigAdvance.startSpan(ctx, "EnhancedAppConfigAdvance-UpdateConfiguration", configurationName)
+	defer func() {
+		span.End(err)
+	}()
+
+	err = appConfigAdvance.schemas.Validate(configurationName, content)
+	if err != nil {
+		return false, err
+	}
+
 	configurationProfileId, found, err := appConfigAdvance.getConfigurationProfileId(ctx, configurationName)
 	if err != nil {
 		return false, err
@@ -143,6 +197,11 @@ func (appConfigAdvance *EnhancedAppConfigAdvance) UpdateConfiguration(ctx contex
 		return false, errors.New(msg)
 	}
 
+	err = appConfigAdvance.attachSchema(ctx, configurationName, configurationProfileId)
+	if err != nil {
+		return false, err
+	}
+
 	// 创建版本
 	createHostedConfigurationVersionOutput, err := appConfigAdvance.createHostedConfigurationVersion(ctx, configurationProfileId, content)
 	if err != nil {
@@ -151,10 +210,17 @@ func (appConfigAdvance *EnhancedAppConfigAdvance) UpdateConfiguration(ctx contex
 
 	// 发布版本
 	configurationVersion := fmt.Sprintf("%d", *createHostedConfigurationVersionOutput.VersionNumber)
+	span.SetAttributes(tracing.Version(configurationVersion))
 	startDeploymentOutput, err := appConfigAdvance.startDeployment(ctx, configurationProfileId, configurationVersion)
 	if err != nil {
+		appConfigAdvance.deploymentsFailed.Add(1, appConfigAdvance.applicationName, appConfigAdvance.environmentName, configurationName)
+		appConfigAdvance.logCtx(ctx).Warn("start deployment of configuration fail",
+			logger.Configuration(configurationName), logger.Version(configurationVersion), logger.Err(err))
 		return false, err
 	}
+	appConfigAdvance.deploymentsStarted.Add(1, appConfigAdvance.applicationName, appConfigAdvance.environmentName, configurationName)
+	appConfigAdvance.logCtx(ctx).Info("configuration updated, deployment started",
+		logger.Configuration(configurationName), logger.Version(configurationVersion))
 	return startDeploymentOutput != nil, nil
 }
 
@@ -177,7 +243,17 @@ func (appConfigAdvance *EnhancedAppConfigAdvance) getConfigurationProfileId(ctx
 	return "", false, nil
 }
 
-func (appConfigAdvance *EnhancedAppConfigAdvance) CreateConfiguration(ctx context.Context, configurationName string, content string) (bool, error) {
+func (appConfigAdvance *EnhancedAppConfigAdvance) CreateConfiguration(ctx context.Context, configurationName string, content string) (_ bool, err error) {
+	ctx, span := appConfigAdvance.startSpan(ctx, "EnhancedAppConfigAdvance-CreateConfiguration", configurationName)
+	defer func() {
+		span.End(err)
+	}()
+
+	err = appConfigAdvance.schemas.Validate(configurationName, content)
+	if err != nil {
+		return false, err
+	}
+
 	_, found, err := appConfigAdvance.getConfigurationProfileId(ctx, configurationName)
 	if err != nil {
 		return false, err
@@ -203,10 +279,17 @@ func (appConfigAdvance *EnhancedAppConfigAdvance) CreateConfiguration(ctx contex
 
 	// 发布版本
 	configurationVersion := fmt.Sprintf("%d", *createHostedConfigurationVersionOutput.VersionNumber)
+	span.SetAttributes(tracing.Version(configurationVersion))
 	startDeploymentOutput, err := appConfigAdvance.startDeployment(ctx, configurationProfileId, configurationVersion)
 	if err != nil {
+		appConfigAdvance.deploymentsFailed.Add(1, appConfigAdvance.applicationName, appConfigAdvance.environmentName, configurationName)
+		appConfigAdvance.logCtx(ctx).Warn("start deployment of configuration fail",
+			logger.Configuration(configurationName), logger.Version(configurationVersion), logger.Err(err))
 		return false, err
 	}
+	appConfigAdvance.deploymentsStarted.Add(1, appConfigAdvance.applicationName, appConfigAdvance.environmentName, configurationName)
+	appConfigAdvance.logCtx(ctx).Info("configuration created, deployment started",
+		logger.Configuration(configurationName), logger.Version(configurationVersion))
 
 	if startDeploymentOutput != nil {
 		configurationProfileNameId.Store(configurationName, configurationProfileId)
@@ -222,7 +305,64 @@ func (appConfigAdvance *EnhancedAppConfigAdvance) createConfigurationProfile(ctx
 		LocationUri: aws.String("hosted"),
 		Name:        aws.String(configurationProfileName),
 	}
-	return appConfigAdvance.appConfigClient.CreateConfigurationProfileWithContext(ctx, &input)
+	s, found := appConfigAdvance.schemas.Get(configurationProfileName)
+	if found {
+		input.Validators = []*appconfig.Validator{
+			{
+				Type:    aws.String(appconfig.ValidatorTypeJsonSchema),
+				Content: aws.String(s.Source()),
+			},
+		}
+	}
+	output, err := appConfigAdvance.appConfigClient.CreateConfigurationProfileWithContext(ctx, &input)
+	if err == nil && found {
+		appConfigAdvance.attachedSchemas.Store(configurationProfileName, s)
+	}
+	return output, err
+}
+
+// attachSchema 将注册的 schema 设置为已有 profile 的 JSON_SCHEMA validator，保留其他 validator
+func (appConfigAdvance *EnhancedAppConfigAdvance) attachSchema(ctx context.Context, configurationName string, configurationProfileId string) error {
+	s, found := appConfigAdvance.schemas.Get(configurationName)
+	if !found {
+		return nil
+	}
+	if attached, ok := appConfigAdvance.attachedSchemas.Load(configurationName); ok && attached == s {
+		return nil
+	}
+
+	profile, err := appConfigAdvance.appConfigClient.GetConfigurationProfileWithContext(ctx, &appconfig.GetConfigurationProfileInput{
+		ApplicationId:          aws.String(appConfigAdvance.applicationId),
+		ConfigurationProfileId: aws.String(configurationProfileId),
+	})
+	if err != nil {
+		return err
+	}
+
+	validators := []*appconfig.Validator{
+		{
+			Type:    aws.String(appconfig.ValidatorTypeJsonSchema),
+			Content: aws.String(s.Source()),
+		},
+	}
+	for _, validator := range profile.Validators {
+		if aws.StringValue(validator.Type) != appconfig.ValidatorTypeJsonSchema {
+			validators = append(validators, validator)
+		}
+	}
+
+	_, err = appConfigAdvance.appConfigClient.UpdateConfigurationProfileWithContext(ctx, &appconfig.UpdateConfigurationProfileInput{
+		ApplicationId:          aws.String(appConfigAdvance.applicationId),
+		ConfigurationProfileId: aws.String(configurationProfileId),
+		Validators:             validators,
+	})
+	if err != nil {
+		return err
+	}
+
+	appConfigAdvance.attachedSchemas.Store(configurationName, s)
+	appConfigAdvance.logCtx(ctx).Info("JSON schema set as validator of configuration profile", logger.Configuration(configurationName))
+	return nil
 }
 
 func (appConfigAdvance *EnhancedAppConfigAdvance) createHostedConfigurationVersion(ctx context.Context, configurationProfileId string, content string) (*appconfig.CreateHostedConfigurationVersionOutput, error) {
@@ -253,7 +393,12 @@ func (appConfigAdvance *EnhancedAppConfigAdvance) startDeployment(ctx context.Co
 	return appConfigAdvance.appConfigClient.StartDeploymentWithContext(ctx, &input)
 }
 
-func (appConfigAdvance *EnhancedAppConfigAdvance) DeleteConfiguration(ctx context.Context, configurationName string) (bool, error) {
+func (appConfigAdvance *EnhancedAppConfigAdvance) DeleteConfiguration(ctx context.Context, configurationName string) (_ bool, err error) {
+	ctx, span := appConfigAdvance.startSpan(ctx, "EnhancedAppConfigAdvance-DeleteConfiguration", configurationName)
+	defer func() {
+		span.End(err)
+	}()
+
 	configurationProfileId, found, err := appConfigAdvance.getConfigurationProfileId(ctx, configurationName)
 	if err != nil {
 		return false, err
@@ -264,8 +409,10 @@ func (appConfigAdvance *EnhancedAppConfigAdvance) DeleteConfiguration(ctx contex
 	}
 	output, err := appConfigAdvance.deleteConfigurationProfile(ctx, configurationProfileId)
 	if err != nil {
+		appConfigAdvance.logCtx(ctx).Warn("delete configuration fail", logger.Configuration(configurationName), logger.Err(err))
 		return false, err
 	}
+	appConfigAdvance.logCtx(ctx).Info("configuration deleted", logger.Configuration(configurationName))
 
 	if output != nil {
 		configurationProfileNameId.Delete(configurationName)
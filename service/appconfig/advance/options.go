@@ -1,5 +1,12 @@
 package appconfigadvance
 
+import (
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/logger"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/metrics"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/tracing"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/tracing/xraytracing"
+)
+
 type Option interface {
 	apply(*EnhancedAppConfigAdvance) error
 }
@@ -36,3 +43,56 @@ func WithEnvironmentName(environmentName string) Option {
 		return nil
 	})
 }
+
+func WithSchema(configurationName string, jsonSchema string) Option {
+	return optionFunc(func(appConfigAdvance *EnhancedAppConfigAdvance) error {
+		return appConfigAdvance.RegisterSchema(configurationName, jsonSchema)
+	})
+}
+
+// WithLogger sets the logger of the client instead of the default logger of
+// the logger package.
+func WithLogger(l logger.Logger) Option {
+	return optionFunc(func(appConfigAdvance *EnhancedAppConfigAdvance) error {
+		appConfigAdvance.logger.Set(l)
+		return nil
+	})
+}
+
+// WithMetrics records the deployments started by the client to provider, nil
+// stops recording.
+func WithMetrics(provider metrics.Provider) Option {
+	return optionFunc(func(appConfigAdvance *EnhancedAppConfigAdvance) error {
+		if provider == nil {
+			provider = metrics.Nop()
+		}
+		appConfigAdvance.setMetrics(provider)
+		return nil
+	})
+}
+
+// WithXRayEnable traces with AWS X-Ray, it is WithTracer(xraytracing.New()).
+// false turns off tracing.
+func WithXRayEnable(isXRayEnable bool) Option {
+	if isXRayEnable {
+		return WithTracer(xraytracing.New())
+	}
+	return WithTracer(nil)
+}
+
+// WithTracer traces the creation of the client, the operations on the
+// configurations and the requests to AWS with tracer, nil turns off tracing.
+func WithTracer(tracer tracing.Tracer) Option {
+	return optionFunc(func(appConfigAdvance *EnhancedAppConfigAdvance) error {
+		if tracer == nil {
+			tracer = tracing.Nop()
+		}
+		appConfigAdvance.tracer = tracer
+
+		// the AWS clients are created again to use the new tracer
+		if appConfigAdvance.appConfigClient != nil {
+			return appConfigAdvance.initAppConfigClient()
+		}
+		return nil
+	})
+}
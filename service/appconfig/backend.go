@@ -0,0 +1,147 @@
+package appconfig
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/logger"
+)
+
+const backendKeyPrefix = "appconfig"
+
+// backendEntry is a configuration shared with the other processes through the
+// cache backend. It holds the raw content, so neither the overrides of this
+// process nor the resolved secrets are written to the backend.
+type backendEntry struct {
+	ClientConfigurationVersion string    `json:"clientConfigurationVersion"`
+	RawContent                 string    `json:"rawContent"`
+	FetchedAt                  time.Time `json:"fetchedAt"`
+}
+
+func (appConfig *EnhancedAppConfig) backendKey(configurationName string) string {
+	return strings.Join([]string{backendKeyPrefix, appConfig.applicationName, appConfig.environmentName, configurationName}, "/")
+}
+
+// getFromBackend returns the entry fetched from AWS AppConfig within the
+// cache refresh interval, or nil. The errors of the backend are only logged,
+// AWS AppConfig is used instead.
+func (appConfig *EnhancedAppConfig) getFromBackend(ctx context.Context, configurationName string) *backendEntry {
+	if appConfig.cacheBackend == nil {
+		return nil
+	}
+
+	data, found, err := appConfig.cacheBackend.Get(ctx, appConfig.backendKey(configurationName))
+	if err != nil {
+		appConfig.logCtx(ctx).Warn("get configuration from cache backend fail", logger.Configuration(configurationName), logger.Err(err))
+		return nil
+	}
+	if !found {
+		return nil
+	}
+
+	var entry backendEntry
+	err = json.Unmarshal(data, &entry)
+	if err != nil {
+		appConfig.logCtx(ctx).Warn("decode configuration from cache backend fail", logger.Configuration(configurationName), logger.Err(err))
+		return nil
+	}
+	if appConfig.clock.Since(entry.FetchedAt) >= appConfig.cacheRefreshInterval {
+		appConfig.logCtx(ctx).Debug("configuration in cache backend is stale", logger.Configuration(configurationName), logger.Any("fetchedAt", entry.FetchedAt))
+		return nil
+	}
+	return &entry
+}
+
+// storeToBackend shares a configuration which has just been fetched from AWS AppConfig.
+func (appConfig *EnhancedAppConfig) storeToBackend(ctx context.Context, configurationName string, configuration *EnhancedConfiguration) {
+	if appConfig.cacheBackend == nil || configuration.rawContent == nil {
+		return
+	}
+
+	data, err := json.Marshal(backendEntry{
+		ClientConfigurationVersion: aws.StringValue(configuration.ClientConfigurationVersion),
+		RawContent:                 *configuration.rawContent,
+		FetchedAt:                  appConfig.clock.Now(),
+	})
+	if err != nil {
+		appConfig.logCtx(ctx).Warn("encode configuration for cache backend fail", logger.Configuration(configurationName), logger.Err(err))
+		return
+	}
+
+	err = appConfig.cacheBackend.Set(ctx, appConfig.backendKey(configurationName), data, 0)
+	if err != nil {
+		appConfig.logCtx(ctx).Warn("set configuration to cache backend fail", logger.Configuration(configurationName), logger.Err(err))
+	}
+}
+
+func (appConfig *EnhancedAppConfig) deleteFromBackend(ctx context.Context, configurationName string) {
+	if appConfig.cacheBackend == nil {
+		return
+	}
+
+	err := appConfig.cacheBackend.Delete(ctx, appConfig.backendKey(configurationName))
+	if err != nil {
+		appConfig.logCtx(ctx).Warn("delete configuration from cache backend fail", logger.Configuration(configurationName), logger.Err(err))
+	}
+}
+
+// renderBackendEntry validates and renders the raw content like a configuration
+// fetched from AWS AppConfig.
+func (appConfig *EnhancedAppConfig) renderBackendEntry(ctx context.Context, configurationName string, entry *backendEntry) (*EnhancedConfiguration, error) {
+	return appConfig.renderRawContent(ctx, configurationName, entry.ClientConfigurationVersion, entry.RawContent)
+}
+
+// loadSharedConfiguration takes the configuration from the cache backend if
+// another process has fetched it recently, otherwise it fetches it from AWS
+// AppConfig and shares it.
+func (appConfig *EnhancedAppConfig) loadSharedConfiguration(ctx context.Context, configurationName string) (*EnhancedConfiguration, error) {
+	if entry := appConfig.getFromBackend(ctx, configurationName); entry != nil {
+		configuration, err := appConfig.renderBackendEntry(ctx, configurationName, entry)
+		if err == nil {
+			appConfig.setFetched(configurationName, entry.FetchedAt)
+			appConfig.logCtx(ctx).Debug("get configuration from cache backend", logger.Configuration(configurationName), logger.Version(entry.ClientConfigurationVersion))
+			return configuration, nil
+		}
+		appConfig.logCtx(ctx).Warn("render configuration from cache backend fail", logger.Configuration(configurationName), logger.Err(err))
+	}
+
+	configuration, err := appConfig.loadConfiguration(ctx, configurationName)
+	if err != nil {
+		return nil, err
+	}
+	appConfig.setFetched(configurationName, appConfig.clock.Now())
+	appConfig.storeToBackend(ctx, configurationName, configuration)
+	return configuration, nil
+}
+
+// refreshFromBackend refreshes a cached configuration from the cache backend
+// if another process has fetched it recently, and returns false if AWS
+// AppConfig must be asked instead.
+func (appConfig *EnhancedAppConfig) refreshFromBackend(ctx context.Context, key string, cached *EnhancedConfiguration) bool {
+	entry := appConfig.getFromBackend(ctx, key)
+	if entry == nil {
+		return false
+	}
+
+	if entry.ClientConfigurationVersion == aws.StringValue(cached.ClientConfigurationVersion) {
+		appConfig.logCtx(ctx).Debug("cache not change of configuration in cache backend", logger.Configuration(key))
+		appConfig.setFetched(key, entry.FetchedAt)
+		appConfig.rerenderSecrets(ctx, key, cached)
+		return true
+	}
+
+	configuration, err := appConfig.renderBackendEntry(ctx, key, entry)
+	if err != nil {
+		appConfig.logCtx(ctx).Warn("render configuration from cache backend fail", logger.Configuration(key), logger.Err(err))
+		return false
+	}
+
+	appConfig.setFetched(key, entry.FetchedAt)
+	appConfig.logCtx(ctx).Warn("cache change of configuration in cache backend", logger.Configuration(key), logger.Version(entry.ClientConfigurationVersion))
+	appConfig.cache.Add(key, configuration)
+	appConfig.notifyListeners(key, configuration)
+	return true
+}
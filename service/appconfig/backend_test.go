@@ -0,0 +1,243 @@
+package appconfig
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-xray-sdk-go/strategy/sampling"
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/cache"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/clock/fakeclock"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/logger"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/tracing/xraytracing"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestAppConfig creates a client of the "app" application in the "test"
+// environment whose AWS clients call endpoint, e.g. the URL of an
+// httptest.Server. The "level" of "my-config" is overridden to "debug" and the
+// "token" secret is "plain".
+func newTestAppConfig(t testing.TB, endpoint string, opts ...Option) *EnhancedAppConfig {
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String(endpoint),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		MaxRetries:  aws.Int(0),
+	}))
+
+	appConfig, err := NewWithOptions(append([]Option{
+		WithApplicationName("app"),
+		WithEnvironmentName("test"),
+		WithRegionName("us-east-1"),
+		WithClientId("client"),
+		WithSession(sess),
+		WithTimeout(time.Second),
+		WithCacheRefreshInterval(time.Minute),
+		WithOverrides(map[string]string{"my-config#level": "debug"}),
+		WithIsSecretResolve(true),
+	}, opts...)...)
+	if err != nil {
+		t.Fatalf("expected nil, but received %v", err)
+	}
+	appConfig.secretResolver.secretsManagerClient = &mockSecretsManager{secrets: map[string]string{"token": "plain"}}
+
+	t.Cleanup(func() {
+		if appConfig.cacheRefreshTicker != nil {
+			appConfig.cacheRefreshTicker.Stop()
+		}
+	})
+	return appConfig
+}
+
+func setBackendEntry(t *testing.T, backend cache.Backend, entry backendEntry) {
+	data, err := json.Marshal(entry)
+	assert.Nil(t, err)
+	assert.Nil(t, backend.Set(context.TODO(), "appconfig/app/test/my-config", data, 0))
+}
+
+func TestBackend_LoadSharedConfiguration(t *testing.T) {
+	backend, err := cache.NewFileBackend(t.TempDir())
+	assert.Nil(t, err)
+	appConfig := newTestAppConfig(t, "", WithCacheBackend(backend))
+
+	setBackendEntry(t, backend, backendEntry{
+		ClientConfigurationVersion: "3",
+		RawContent:                 `{"level": "info", "token": "${secretsmanager:token}"}`,
+		FetchedAt:                  time.Now(),
+	})
+
+	configuration, err := appConfig.GetEnhancedConfiguration(context.TODO(), "my-config")
+	assert.Nil(t, err)
+	assert.Equal(t, "3", aws.StringValue(configuration.ClientConfigurationVersion))
+	assert.JSONEq(t, `{"level": "debug", "token": "plain"}`, *configuration.Content)
+	assert.False(t, configuration.IsCache)
+
+	configuration, err = appConfig.GetEnhancedConfiguration(context.TODO(), "my-config")
+	assert.Nil(t, err)
+	assert.True(t, configuration.IsCache)
+}
+
+func TestBackend_StoreToBackend(t *testing.T) {
+	backend, err := cache.NewFileBackend(t.TempDir())
+	assert.Nil(t, err)
+	appConfig := newTestAppConfig(t, "", WithCacheBackend(backend))
+
+	rawContent := `{"token": "${secretsmanager:token}"}`
+	content := `{"token": "plain"}`
+	appConfig.storeToBackend(context.TODO(), "my-config", &EnhancedConfiguration{
+		ClientConfigurationVersion: aws.String("1"),
+		Content:                    &content,
+		rawContent:                 &rawContent,
+	})
+
+	// the resolved secret is not written
+	data, found, err := backend.Get(context.TODO(), "appconfig/app/test/my-config")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.NotContains(t, string(data), "plain")
+
+	entry := appConfig.getFromBackend(context.TODO(), "my-config")
+	assert.NotNil(t, entry)
+	assert.Equal(t, "1", entry.ClientConfigurationVersion)
+	assert.Equal(t, rawContent, entry.RawContent)
+}
+
+func TestBackend_RefreshFromBackend(t *testing.T) {
+	backend, err := cache.NewFileBackend(t.TempDir())
+	assert.Nil(t, err)
+	clk := fakeclock.New(time.Now())
+	appConfig := newTestAppConfig(t, "", WithCacheBackend(backend), WithClock(clk))
+	// the test refreshes by itself
+	appConfig.cacheRefreshTicker.Stop()
+
+	rawContent := `{"level": "info"}`
+	content := `{"level": "debug"}`
+	cached := &EnhancedConfiguration{
+		ClientConfigurationVersion: aws.String("1"),
+		Content:                    &content,
+		IsCache:                    true,
+		rawContent:                 &rawContent,
+	}
+	appConfig.cache.Add("my-config", cached)
+
+	// nothing shared, AWS AppConfig must be asked
+	assert.False(t, appConfig.refreshFromBackend(context.TODO(), "my-config", cached))
+
+	// an entry fetched a refresh interval ago is stale
+	setBackendEntry(t, backend, backendEntry{
+		ClientConfigurationVersion: "2",
+		RawContent:                 `{"level": "warn", "name": "stale"}`,
+		FetchedAt:                  clk.Now(),
+	})
+	clk.Advance(time.Minute)
+	assert.False(t, appConfig.refreshFromBackend(context.TODO(), "my-config", cached))
+
+	// the same version is not changed
+	setBackendEntry(t, backend, backendEntry{
+		ClientConfigurationVersion: "1",
+		RawContent:                 rawContent,
+		FetchedAt:                  clk.Now(),
+	})
+	assert.True(t, appConfig.refreshFromBackend(context.TODO(), "my-config", cached))
+	configuration, _ := appConfig.cache.Get("my-config")
+	assert.Same(t, cached, configuration)
+
+	// a new version fetched by another process is taken
+	setBackendEntry(t, backend, backendEntry{
+		ClientConfigurationVersion: "2",
+		RawContent:                 `{"level": "warn", "name": "new"}`,
+		FetchedAt:                  clk.Now(),
+	})
+	assert.True(t, appConfig.refreshFromBackend(context.TODO(), "my-config", cached))
+	configuration, _ = appConfig.cache.Get("my-config")
+	assert.Equal(t, "2", aws.StringValue(configuration.ClientConfigurationVersion))
+	assert.JSONEq(t, `{"level": "debug", "name": "new"}`, *configuration.Content)
+}
+
+func TestBackend_CacheRefreshTicker(t *testing.T) {
+	backend, err := cache.NewFileBackend(t.TempDir())
+	assert.Nil(t, err)
+	clk := fakeclock.New(time.Now())
+	appConfig := newTestAppConfig(t, "", WithCacheBackend(backend), WithClock(clk))
+
+	rawContent := `{"level": "info"}`
+	appConfig.cache.Add("my-config", &EnhancedConfiguration{
+		ClientConfigurationVersion: aws.String("1"),
+		Content:                    &rawContent,
+		IsCache:                    true,
+		rawContent:                 &rawContent,
+	})
+	clk.WaitForTimers(1)
+
+	// another process has fetched a new version, it is taken by the next refresh
+	clk.Advance(time.Second * 30)
+	setBackendEntry(t, backend, backendEntry{
+		ClientConfigurationVersion: "2",
+		RawContent:                 `{"level": "warn"}`,
+		FetchedAt:                  clk.Now(),
+	})
+	assert.Equal(t, int64(0), appConfig.RefreshStats().Runs)
+
+	clk.Advance(time.Second * 30)
+	assert.Eventually(t, func() bool {
+		return appConfig.RefreshStats().Runs == 1
+	}, time.Second, time.Millisecond)
+
+	configuration, found := appConfig.cache.Get("my-config")
+	assert.True(t, found)
+	assert.Equal(t, "2", aws.StringValue(configuration.ClientConfigurationVersion))
+	assert.JSONEq(t, `{"level": "debug"}`, *configuration.Content)
+}
+
+func TestBackend_LogContext(t *testing.T) {
+	backend, err := cache.NewFileBackend(t.TempDir())
+	assert.Nil(t, err)
+	appConfig := newTestAppConfig(t, "", WithCacheBackend(backend), WithTracer(xraytracing.New()))
+	client := newRecordLogger()
+	assert.Nil(t, appConfig.ApplyWithOptions(WithLogger(client)))
+
+	rawContent := `{"level": "info"}`
+	appConfig.cache.Add("my-config", &EnhancedConfiguration{
+		ClientConfigurationVersion: aws.String("1"),
+		Content:                    &rawContent,
+		IsCache:                    true,
+		rawContent:                 &rawContent,
+	})
+	setBackendEntry(t, backend, backendEntry{
+		ClientConfigurationVersion: "2",
+		RawContent:                 `{"level": "warn"}`,
+		FetchedAt:                  appConfig.clock.Now(),
+	})
+
+	// the logger and the fields of the context are used for the call
+	request := newRecordLogger()
+	ctx := logger.NewContext(logger.ContextWithRequestID(context.Background(), "req-1"), request)
+	// the default centralized sampling polls the X-Ray daemon in the background
+	strategy, err := sampling.NewLocalizedStrategy()
+	assert.Nil(t, err)
+	ctx, err = xray.ContextWithConfig(ctx, xray.Config{SamplingStrategy: strategy})
+	assert.Nil(t, err)
+	ctx, segment := xray.BeginSegment(ctx, "test")
+	defer segment.Close(nil)
+	appConfig.Refresh(ctx, "my-config")
+
+	assert.Empty(t, client.sink.entries)
+	var changed *recordEntry
+	for i, entry := range request.sink.entries {
+		assert.Equal(t, "req-1", entry.fields[logger.RequestIDKey])
+		assert.Equal(t, segment.TraceID, entry.fields[logger.TraceIDKey])
+		assert.Equal(t, "app", entry.fields[logger.ApplicationKey])
+		if entry.msg == "cache change of configuration in cache backend" {
+			changed = &request.sink.entries[i]
+		}
+	}
+	if assert.NotNil(t, changed) {
+		assert.Equal(t, "my-config", changed.fields[logger.ConfigurationKey])
+		assert.Equal(t, "2", changed.fields[logger.VersionKey])
+	}
+}
@@ -0,0 +1,419 @@
+package appconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bindTagName is the struct tag read by Bind, "-" skips the field, e.g.
+// `appconfig:"server.port,default=8080,required,min=1,max=65535,enum=a|b,regex=<last>"`
+const bindTagName = "appconfig"
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+type bindOptions struct {
+	path         string
+	defaultValue *string
+	required     bool
+	min          *float64
+	max          *float64
+	enum         []string
+	regex        *regexp.Regexp
+}
+
+// FieldError is a single field of a bound struct that can not be decoded or
+// does not pass its validation.
+type FieldError struct {
+	// Field is the Go field path, e.g. Database.Port
+	Field string
+	// Path is the key path inside the configuration content, e.g. database.port
+	Path string
+	Err  error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("field [%s] path [%s]: %v", e.Field, e.Path, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// BindError aggregates all the field errors of one configuration.
+type BindError struct {
+	ConfigurationName string
+	FieldErrors       []*FieldError
+}
+
+func (e *BindError) Error() string {
+	msgs := make([]string, 0, len(e.FieldErrors))
+	for _, fieldError := range e.FieldErrors {
+		msgs = append(msgs, fieldError.Error())
+	}
+	return fmt.Sprintf("bind configuration [%s] fail: %s", e.ConfigurationName, strings.Join(msgs, "; "))
+}
+
+// Binding is the Value of a configuration decoded into the struct T with the
+// "appconfig" tags, an invalid version is reported by Err and not applied.
+type Binding[T any] struct {
+	*Value[T]
+}
+
+// Bind decodes the configuration into the struct T, it returns a *BindError
+// with all the invalid fields when the content does not validate.
+func Bind[T any](ctx context.Context, appConfig *EnhancedAppConfig, configurationName string) (*Binding[T], error) {
+	if reflect.TypeOf((*T)(nil)).Elem().Kind() != reflect.Struct {
+		return nil, errors.New("bind target must be a struct")
+	}
+
+	value, err := NewValue[T](ctx, appConfig, configurationName)
+	if err != nil {
+		return nil, err
+	}
+	return &Binding[T]{Value: value}, nil
+}
+
+// decodeStruct decodes the JSON content into target, a pointer to a struct.
+func decodeStruct(configurationName string, content string, target interface{}) error {
+	decoder := json.NewDecoder(strings.NewReader(content))
+	decoder.UseNumber()
+
+	var document map[string]interface{}
+	err := decoder.Decode(&document)
+	if err != nil {
+		return fmt.Errorf("decode configuration [%s] fail, content must be a JSON object: %w", configurationName, err)
+	}
+
+	var fieldErrors []*FieldError
+	decodeFields(document, reflect.ValueOf(target).Elem(), "", "", &fieldErrors)
+	if len(fieldErrors) > 0 {
+		return &BindError{
+			ConfigurationName: configurationName,
+			FieldErrors:       fieldErrors,
+		}
+	}
+	return nil
+}
+
+func decodeFields(document map[string]interface{}, structValue reflect.Value, fieldPrefix, pathPrefix string, fieldErrors *[]*FieldError) {
+	structType := structValue.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		structField := structType.Field(i)
+		if structField.PkgPath != "" {
+			// unexported
+			continue
+		}
+
+		tag := structField.Tag.Get(bindTagName)
+		if tag == "-" {
+			continue
+		}
+
+		fieldName := joinPath(fieldPrefix, structField.Name)
+		opts, err := parseBindTag(tag)
+		if err != nil {
+			*fieldErrors = append(*fieldErrors, &FieldError{Field: fieldName, Path: pathPrefix, Err: err})
+			continue
+		}
+		if opts.path == "" {
+			opts.path = structField.Name
+		}
+		path := joinPath(pathPrefix, opts.path)
+
+		fieldError := func(err error) {
+			*fieldErrors = append(*fieldErrors, &FieldError{Field: fieldName, Path: path, Err: err})
+		}
+
+		fieldValue := structValue.Field(i)
+		raw, found, err := lookupPath(document, path)
+		if err != nil {
+			fieldError(err)
+			continue
+		}
+		// null is missing, so that required rejects it and the default applies
+		found = found && raw != nil
+
+		if isNestedStruct(fieldValue) {
+			if !found && opts.required {
+				fieldError(errors.New("is required"))
+				continue
+			}
+			decodeFields(document, fieldValue, fieldName, path, fieldErrors)
+			continue
+		}
+
+		switch {
+		case found:
+			err = assignValue(fieldValue, raw)
+		case opts.defaultValue != nil:
+			err = assignDefault(fieldValue, *opts.defaultValue)
+		case opts.required:
+			err = errors.New("is required")
+		default:
+			continue
+		}
+		if err != nil {
+			fieldError(err)
+			continue
+		}
+
+		for _, err := range validateField(fieldValue, opts) {
+			fieldError(err)
+		}
+	}
+}
+
+func parseBindTag(tag string) (*bindOptions, error) {
+	opts := &bindOptions{}
+	if tag == "" {
+		return opts, nil
+	}
+
+	parts := strings.Split(tag, ",")
+	opts.path = parts[0]
+	for i := 1; i < len(parts); i++ {
+		name, value, hasValue := cutOption(parts[i])
+		switch name {
+		case "required":
+			opts.required = true
+		case "default":
+			defaultValue := value
+			opts.defaultValue = &defaultValue
+		case "min", "max":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil || !hasValue {
+				return nil, fmt.Errorf("invalid tag option [%s]", parts[i])
+			}
+			if name == "min" {
+				opts.min = &n
+			} else {
+				opts.max = &n
+			}
+		case "enum":
+			opts.enum = strings.Split(value, "|")
+		case "regex":
+			// the expression may contain ",", it takes the rest of the tag
+			expr := strings.Join(append([]string{value}, parts[i+1:]...), ",")
+			regex, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex [%s]: %w", expr, err)
+			}
+			opts.regex = regex
+			i = len(parts)
+		default:
+			return nil, fmt.Errorf("unknown tag option [%s]", parts[i])
+		}
+	}
+	return opts, nil
+}
+
+func cutOption(option string) (string, string, bool) {
+	i := strings.Index(option, "=")
+	if i < 0 {
+		return strings.TrimSpace(option), "", false
+	}
+	return strings.TrimSpace(option[:i]), option[i+1:], true
+}
+
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// lookupPath walks the dot separated path, a key is matched exactly first and
+// then case-insensitively, several keys matching case-insensitively is an error.
+func lookupPath(document map[string]interface{}, path string) (interface{}, bool, error) {
+	var current interface{} = document
+	for _, segment := range strings.Split(path, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false, nil
+		}
+		value, found := object[segment]
+		if !found {
+			var matched []string
+			for key, v := range object {
+				if strings.EqualFold(key, segment) {
+					value, found = v, true
+					matched = append(matched, key)
+				}
+			}
+			if len(matched) > 1 {
+				sort.Strings(matched)
+				return nil, false, fmt.Errorf("ambiguous keys %v", matched)
+			}
+		}
+		if !found {
+			return nil, false, nil
+		}
+		current = value
+	}
+	return current, true, nil
+}
+
+func isNestedStruct(fieldValue reflect.Value) bool {
+	if fieldValue.Kind() != reflect.Struct {
+		return false
+	}
+	// types like time.Time decode themselves
+	_, ok := fieldValue.Addr().Interface().(json.Unmarshaler)
+	return !ok
+}
+
+// hasBindTags reports whether an exported field of the struct, or of its
+// nested structs, has an "appconfig" tag.
+func hasBindTags(structType reflect.Type) bool {
+	for i := 0; i < structType.NumField(); i++ {
+		structField := structType.Field(i)
+		if structField.PkgPath != "" {
+			continue
+		}
+		if _, ok := structField.Tag.Lookup(bindTagName); ok {
+			return true
+		}
+		if structField.Type.Kind() == reflect.Struct && !reflect.PtrTo(structField.Type).Implements(jsonUnmarshalerType) && hasBindTags(structField.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+func assignValue(fieldValue reflect.Value, raw interface{}) error {
+	if fieldValue.Type() == durationType {
+		if s, ok := raw.(string); ok {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return err
+			}
+			fieldValue.SetInt(int64(d))
+			return nil
+		}
+	}
+
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, fieldValue.Addr().Interface())
+}
+
+func assignDefault(fieldValue reflect.Value, literal string) error {
+	switch {
+	case fieldValue.Type() == durationType:
+		d, err := time.ParseDuration(literal)
+		if err != nil {
+			return fmt.Errorf("invalid default [%s]: %w", literal, err)
+		}
+		fieldValue.SetInt(int64(d))
+		return nil
+	case fieldValue.Kind() == reflect.String:
+		fieldValue.SetString(literal)
+		return nil
+	case fieldValue.Kind() == reflect.Slice:
+		var buf bytes.Buffer
+		buf.WriteString("[")
+		isString := fieldValue.Type().Elem().Kind() == reflect.String
+		for i, element := range strings.Split(literal, "|") {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			if isString {
+				b, _ := json.Marshal(element)
+				buf.Write(b)
+			} else {
+				buf.WriteString(element)
+			}
+		}
+		buf.WriteString("]")
+		literal = buf.String()
+	}
+
+	err := json.Unmarshal([]byte(literal), fieldValue.Addr().Interface())
+	if err != nil {
+		return fmt.Errorf("invalid default [%s]: %w", literal, err)
+	}
+	return nil
+}
+
+func validateField(fieldValue reflect.Value, opts *bindOptions) []error {
+	for fieldValue.Kind() == reflect.Ptr {
+		if fieldValue.IsNil() {
+			return nil
+		}
+		fieldValue = fieldValue.Elem()
+	}
+
+	var errs []error
+	if opts.min != nil || opts.max != nil {
+		n, isLength, ok := measure(fieldValue)
+		if !ok {
+			errs = append(errs, fmt.Errorf("min/max is not supported for %s", fieldValue.Kind()))
+		} else {
+			what := "value"
+			if isLength {
+				what = "length"
+			}
+			if opts.min != nil && n < *opts.min {
+				errs = append(errs, fmt.Errorf("%s %v must be at least %v", what, n, *opts.min))
+			}
+			if opts.max != nil && n > *opts.max {
+				errs = append(errs, fmt.Errorf("%s %v must be at most %v", what, n, *opts.max))
+			}
+		}
+	}
+
+	if len(opts.enum) > 0 {
+		s := fmt.Sprint(fieldValue.Interface())
+		if !containsString(opts.enum, s) {
+			errs = append(errs, fmt.Errorf("value [%s] must be one of [%s]", s, strings.Join(opts.enum, ", ")))
+		}
+	}
+
+	if opts.regex != nil {
+		if fieldValue.Kind() != reflect.String {
+			errs = append(errs, fmt.Errorf("regex is not supported for %s", fieldValue.Kind()))
+		} else if !opts.regex.MatchString(fieldValue.String()) {
+			errs = append(errs, fmt.Errorf("value [%s] must match [%s]", fieldValue.String(), opts.regex))
+		}
+	}
+
+	return errs
+}
+
+// measure returns the number to compare with min/max, and whether it is a length.
+func measure(fieldValue reflect.Value) (float64, bool, bool) {
+	switch fieldValue.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(fieldValue.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(fieldValue.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return fieldValue.Float(), false, true
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return float64(fieldValue.Len()), true, true
+	}
+	return 0, false, false
+}
+
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package appconfig
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type bindDatabase struct {
+	Host string `appconfig:"host,required"`
+	Port int    `appconfig:"port,default=5432,min=1,max=65535"`
+}
+
+type bindConfig struct {
+	Name     string        `appconfig:"name,required,regex=^[a-z]{1,5}$"`
+	Level    string        `appconfig:"log.level,default=info,enum=debug|info|warn"`
+	Timeout  time.Duration `appconfig:"timeout,default=3s"`
+	Tags     []string      `appconfig:"tags,default=a|b,max=3"`
+	Ratio    float64       `appconfig:"ratio,min=0,max=1"`
+	Database bindDatabase  `appconfig:"database"`
+	Ignored  string        `appconfig:"-"`
+	Enabled  bool
+}
+
+func TestBind_DecodeStruct(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		want        bindConfig
+		wantFields  []string
+		wantDecoded bool
+	}{
+		{
+			name:    "defaults",
+			content: `{"name": "foo", "database": {"host": "localhost"}}`,
+			want: bindConfig{
+				Name:     "foo",
+				Level:    "info",
+				Timeout:  time.Second * 3,
+				Tags:     []string{"a", "b"},
+				Database: bindDatabase{Host: "localhost", Port: 5432},
+			},
+			wantDecoded: true,
+		},
+		{
+			name: "values",
+			content: `{"name": "bar", "log": {"level": "warn"}, "timeout": "1m", "tags": ["x"], "ratio": 0.5,
+				"database": {"host": "db", "port": 3306}, "Ignored": "x", "enabled": true}`,
+			want: bindConfig{
+				Name:     "bar",
+				Level:    "warn",
+				Timeout:  time.Minute,
+				Tags:     []string{"x"},
+				Ratio:    0.5,
+				Database: bindDatabase{Host: "db", Port: 3306},
+				Enabled:  true,
+			},
+			wantDecoded: true,
+		},
+		{
+			name:        "invalid",
+			content:     `{"name": "TOO-LONG", "log": {"level": "trace"}, "tags": ["1", "2", "3", "4"], "ratio": 2, "database": {"port": 0}}`,
+			wantFields:  []string{"Name", "Level", "Tags", "Ratio", "Database.Host", "Database.Port"},
+			wantDecoded: false,
+		},
+		{
+			name:        "missing required",
+			content:     `{}`,
+			wantFields:  []string{"Name", "Database.Host"},
+			wantDecoded: false,
+		},
+		{
+			name:        "null",
+			content:     `{"name": null, "log": {"level": null}, "database": {"host": null}}`,
+			wantFields:  []string{"Name", "Database.Host"},
+			wantDecoded: false,
+		},
+		{
+			name:    "exact key",
+			content: `{"name": "foo", "database": {"host": "db"}, "Enabled": true, "enabled": false}`,
+			want: bindConfig{
+				Name:     "foo",
+				Level:    "info",
+				Timeout:  time.Second * 3,
+				Tags:     []string{"a", "b"},
+				Database: bindDatabase{Host: "db", Port: 5432},
+				Enabled:  true,
+			},
+			wantDecoded: true,
+		},
+		{
+			name:        "ambiguous keys",
+			content:     `{"name": "foo", "database": {"host": "db"}, "ENABLED": true, "enabled": false}`,
+			wantFields:  []string{"Enabled"},
+			wantDecoded: false,
+		},
+		{
+			name:        "wrong type",
+			content:     `{"name": "foo", "timeout": "soon", "database": {"host": 1}}`,
+			wantFields:  []string{"Timeout", "Database.Host"},
+			wantDecoded: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got bindConfig
+			err := decodeStruct("test", tt.content, &got)
+			if tt.wantDecoded {
+				assert.Nil(t, err)
+				assert.Equal(t, tt.want, got)
+				return
+			}
+
+			var bindError *BindError
+			assert.True(t, errors.As(err, &bindError), "expected *BindError, but received %v", err)
+
+			var fields []string
+			for _, fieldError := range bindError.FieldErrors {
+				fields = append(fields, fieldError.Field)
+			}
+			assert.Equal(t, tt.wantFields, fields)
+		})
+	}
+}
+
+func TestBind_Update(t *testing.T) {
+	content := `{"name": "foo", "database": {"host": "db1"}}`
+	version := "1"
+
+	binding := &Binding[bindConfig]{Value: newTestValue[bindConfig](t, content, version)}
+
+	invalidContent := `{"name": "foo"}`
+	invalidVersion := "2"
+	binding.holder.update(&EnhancedConfiguration{ClientConfigurationVersion: &invalidVersion, Content: &invalidContent})
+	assert.NotNil(t, binding.Err())
+	assert.Equal(t, "1", binding.Version())
+	assert.Equal(t, "db1", binding.Load().Database.Host)
+
+	validContent := `{"name": "foo", "database": {"host": "db3"}}`
+	validVersion := "3"
+	binding.holder.update(&EnhancedConfiguration{ClientConfigurationVersion: &validVersion, Content: &validContent})
+	assert.Nil(t, binding.Err())
+	assert.Equal(t, "3", binding.Version())
+	assert.Equal(t, "db3", binding.Load().Database.Host)
+}
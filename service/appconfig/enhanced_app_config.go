@@ -4,29 +4,42 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"github.com/aws/aws-xray-sdk-go/xray"
 	"os"
 	"runtime/debug"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/appconfig"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/google/uuid"
 	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/cache"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/clock"
 	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/constant"
 	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/logger"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/metrics"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/schema"
 	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/ticker"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/tracing"
 )
 
 const (
 	defaultIsCache = true
-	// the max number of configurations that can be cached
+	// 最多缓存的配置数量
 	defaultCacheLimit           = int64(500)
 	defaultCacheRefreshInterval = time.Second * 300
 	defaultTimeout              = time.Second * 10
+	defaultIsSecretResolve      = true
+	defaultSecretCacheTTL       = time.Second * 300
+	// 后台刷新的日志每个配置每 10 分钟只记录一次
+	defaultLogSamplingFirst    = 1
+	defaultLogSamplingInterval = time.Minute * 10
 )
 
 type EnhancedAppConfig struct {
@@ -35,22 +48,72 @@ type EnhancedAppConfig struct {
 	clientId        string
 
 	regionName           string
-	isCache              bool          // 是否开启全局缓存
-	cacheLimit           int64         // 最多缓存多少个配置
-	cacheRefreshInterval time.Duration // 缓存刷新间隔
-	timeout              time.Duration // 获取配置的超时时间
-
-	isXRayEnable bool // 是否开启 X-Ray
-
+	isCache              bool                 // 是否开启全局缓存
+	cacheLimit           int64                // 最多缓存多少个配置
+	cacheEvictionPolicy  cache.EvictionPolicy // 超过 cacheLimit 时删除哪个配置
+	cacheMaxWeight       int64                // 缓存配置内容的最大总字节数，0 表示不限制
+	cacheShards          int                  // 缓存分片数，0 表示按 cacheLimit 和 GOMAXPROCS 决定
+	cacheRefreshInterval time.Duration        // 缓存刷新间隔
+	cacheRefreshSchedule ticker.Schedule      // 不为空时代替 cacheRefreshInterval 决定何时刷新缓存
+	cacheRefreshJitter   time.Duration        // 每次刷新缓存随机延迟的最大值
+	cacheBackend         cache.Backend        // 多个进程共享的二级缓存，可以为空
+	timeout              time.Duration        // 获取配置的超时时间
+	clock                clock.Clock          // 缓存过期和刷新使用的时钟
+	logger               logger.Holder        // 为空时使用 logger.Default()
+	logSamplingFirst     int                  // 后台刷新时每个采样间隔内同一条日志最多打印几次，0 表示不采样
+	logSamplingInterval  time.Duration        // 后台刷新的日志采样间隔
+	logSampler           atomic.Value         // *logger.Sampler，为 nil 时不采样
+	metricsProvider      metrics.Provider     // 为空时不记录指标
+	metrics              *appConfigMetrics
+
+	tracer tracing.Tracer // 默认不追踪，WithXRayEnable 使用 X-Ray
+
+	isSecretResolve bool // 是否解析配置中的 ${secretsmanager:...} 和 ${ssm:...}
+	secretResolver  *secretResolver
+
+	session            *session.Session // 为空时用 regionName 创建
 	appConfigClient    *appconfig.AppConfig
-	cache              *cache.Cache
+	cache              *cache.Cache[string, *EnhancedConfiguration]
 	cacheRefreshTicker *ticker.Ticker
+
+	// 拉取到的配置必须匹配的 JSON schema
+	schemas *schema.Registry
+
+	overrides     Overrides         // 优先于 AWS AppConfig 的配置
+	envOverrides  map[string]string // 环境变量中的 override，key 不含前缀
+	overridesLock sync.RWMutex
+
+	// 配置有新版本或被移出缓存时通知 listeners
+	listeners     map[string][]listener
+	listenersLock sync.RWMutex
+
+	onEvict     []func(configurationName string, reason cache.EvictionReason)
+	onEvictLock sync.RWMutex
+
+	// 每个缓存的配置最后一次从 AWS AppConfig 拉取的时间
+	lastFetched     map[string]time.Time
+	lastFetchedLock sync.Mutex
+
+	// NewWithOptions 返回后设置，之后的 option 修改运行中的客户端
+	created bool
+}
+
+type listener interface {
+	update(configuration *EnhancedConfiguration)
+	evicted(reason cache.EvictionReason)
+	// 缓存关闭时调用
+	stopped()
+	// listener 当前的版本，配置移出缓存后据此刷新
+	version() string
 }
 
 type EnhancedConfiguration struct {
 	ClientConfigurationVersion *string
 	Content                    *string
 	IsCache                    bool
+
+	// 从 AWS AppConfig 拉取的原始内容，未应用覆盖值
+	rawContent *string
 }
 
 func NewWithApplicationName(applicationName string) (*EnhancedAppConfig, error) {
@@ -67,7 +130,17 @@ func NewWithOptions(opts ...Option) (*EnhancedAppConfig, error) {
 		cacheLimit:           defaultCacheLimit,
 		cacheRefreshInterval: defaultCacheRefreshInterval,
 		timeout:              defaultTimeout,
-	}
+		schemas:              schema.NewRegistry(),
+		envOverrides:         loadEnvOverrides(),
+		isSecretResolve:      defaultIsSecretResolve,
+		clock:                clock.New(),
+		logSamplingFirst:     defaultLogSamplingFirst,
+		logSamplingInterval:  defaultLogSamplingInterval,
+		metrics:              newAppConfigMetrics(metrics.Nop(), nil),
+		lastFetched:          map[string]time.Time{},
+		tracer:               tracing.Nop(),
+	}
+	appConfig.secretResolver = newSecretResolver(defaultSecretCacheTTL, appConfig.clock)
 
 	err := appConfig.ApplyWithOptions(opts...)
 	if err != nil {
@@ -99,28 +172,136 @@ func NewWithOptions(opts ...Option) (*EnhancedAppConfig, error) {
 		if appConfig.isCache {
 			appConfig.initCache()
 		} else {
-			logger.Warn("cache is off, application name: ", appConfig.applicationName, ", environment name: ", appConfig.environmentName)
+			appConfig.log().Warn("cache is off")
 		}
 	}
 
+	appConfig.created = true
 	return appConfig, nil
 }
 
+// log 返回带有应用、环境和客户端 id 的 logger
+func (appConfig *EnhancedAppConfig) log() logger.Logger {
+	return logger.With(appConfig.logger.Load(), appConfig.logFields()...)
+}
+
+// logCtx 返回 ctx 中的 logger，被追踪时加上 trace id
+func (appConfig *EnhancedAppConfig) logCtx(ctx context.Context) logger.Logger {
+	fields := appConfig.logFields()
+	if traceID := appConfig.tracer.TraceID(ctx); traceID != "" {
+		fields = append(fields, logger.TraceID(traceID))
+	}
+	return logger.FromContext(ctx, appConfig.logger.Load()).With(fields...)
+}
+
+func (appConfig *EnhancedAppConfig) logFields() []logger.Field {
+	return []logger.Field{
+		logger.Application(appConfig.applicationName),
+		logger.Environment(appConfig.environmentName),
+		logger.ClientID(appConfig.clientId),
+	}
+}
+
+// updateLoggers 将客户端的 logger 传给缓存和 ticker
+func (appConfig *EnhancedAppConfig) updateLoggers() {
+	l := appConfig.log()
+	if appConfig.secretResolver != nil {
+		appConfig.secretResolver.secrets.SetLogger(l)
+	}
+	if appConfig.cache != nil {
+		appConfig.cache.SetLogger(l)
+	}
+	if appConfig.cacheRefreshTicker != nil {
+		appConfig.cacheRefreshTicker.SetLogger(l)
+	}
+}
+
 func (appConfig *EnhancedAppConfig) initCache() {
-	logger.Info("start init cache and ticker, cacheLimit: ", appConfig.cacheLimit, ", cacheRefreshInterval: ", appConfig.cacheRefreshInterval)
-	appConfig.cache = cache.New(appConfig.cacheLimit)
+	appConfig.log().Info("start init cache and ticker", logger.Any("cacheLimit", appConfig.cacheLimit), logger.Any("cacheMaxWeight", appConfig.cacheMaxWeight), logger.Any("cacheRefreshInterval", appConfig.cacheRefreshInterval))
+	appConfig.cache = appConfig.newCache()
 	appConfig.initRefreshCacheTicker()
-	logger.Info("init cache and ticker end")
+	appConfig.log().Info("init cache and ticker end")
 }
 
-func (appConfig *EnhancedAppConfig) initAppConfigClient() error {
-	awsConfig := aws.Config{
-		Region: aws.String(appConfig.regionName),
+func (appConfig *EnhancedAppConfig) newCache() *cache.Cache[string, *EnhancedConfiguration] {
+	shards := appConfig.cacheShards
+	if shards == 0 {
+		shards = cache.DefaultShards(appConfig.cacheLimit)
+	}
+	c := cache.New[string, *EnhancedConfiguration](appConfig.cacheLimit,
+		cache.WithEvictionPolicy(appConfig.cacheEvictionPolicy),
+		cache.WithShards(shards),
+		cache.WithMaxWeight(appConfig.cacheMaxWeight),
+		cache.WithClock(appConfig.clock),
+		cache.WithLogger(appConfig.log()),
+		cache.WithMetrics(appConfig.metricsProvider, appConfig.cacheName()),
+	)
+	c.SetWeigher(weighConfiguration)
+	c.SetCodec(appConfig.encodeSnapshot, appConfig.decodeSnapshot)
+	c.OnEvict(func(key string, _ *EnhancedConfiguration, reason cache.EvictionReason) {
+		appConfig.onCacheEvict(key, reason)
+	})
+	return c
+}
+
+// weighConfiguration 按内容的字节数计算配置的权重
+func weighConfiguration(_ string, configuration *EnhancedConfiguration) int64 {
+	if configuration == nil || configuration.Content == nil {
+		return 0
+	}
+	return int64(len(*configuration.Content))
+}
+
+func (appConfig *EnhancedAppConfig) onCacheEvict(configurationName string, reason cache.EvictionReason) {
+	appConfig.onEvictLock.RLock()
+	callbacks := appConfig.onEvict
+	appConfig.onEvictLock.RUnlock()
+
+	for _, callback := range callbacks {
+		callback(configurationName, reason)
+	}
+
+	if reason != cache.ReasonReplaced {
+		appConfig.forgetFetched(configurationName)
+		appConfig.notifyEvicted(configurationName, reason)
 	}
+}
 
-	sess := session.Must(session.NewSessionWithOptions(session.Options{
-		Config: awsConfig,
-	}))
+// RefreshStats 返回缓存刷新的统计，缓存关闭时为零值
+func (appConfig *EnhancedAppConfig) RefreshStats() ticker.Stats {
+	if appConfig.cacheRefreshTicker == nil {
+		return ticker.Stats{}
+	}
+	return appConfig.cacheRefreshTicker.Stats()
+}
+
+// CacheStats 返回缓存的统计，缓存关闭时为零值
+func (appConfig *EnhancedAppConfig) CacheStats() cache.Stats {
+	if appConfig.cache == nil {
+		return cache.Stats{}
+	}
+	return appConfig.cache.Stats()
+}
+
+// OnEvict 注册配置被移出或替换时的回调
+func (appConfig *EnhancedAppConfig) OnEvict(callback func(configurationName string, reason cache.EvictionReason)) {
+	appConfig.onEvictLock.Lock()
+	defer appConfig.onEvictLock.Unlock()
+
+	appConfig.onEvict = append(appConfig.onEvict, callback)
+}
+
+func (appConfig *EnhancedAppConfig) initAppConfigClient() error {
+	sess := appConfig.session
+	if sess == nil {
+		awsConfig := aws.Config{
+			Region: aws.String(appConfig.regionName),
+		}
+
+		sess = session.Must(session.NewSessionWithOptions(session.Options{
+			Config: awsConfig,
+		}))
+	}
 
 	appConfigClient := appconfig.New(sess)
 
@@ -128,97 +309,208 @@ func (appConfig *EnhancedAppConfig) initAppConfigClient() error {
 		return errors.New("can not init aws AppConfig client")
 	}
 
-	if appConfig.isXRayEnable {
-		xray.AWS(appConfigClient.Client)
-	}
+	secretsManagerClient := secretsmanager.New(sess)
+	ssmClient := ssm.New(sess)
+
+	appConfig.tracer.InstrumentClient(appConfigClient.Client)
+	appConfig.tracer.InstrumentClient(secretsManagerClient.Client)
+	appConfig.tracer.InstrumentClient(ssmClient.Client)
 
 	appConfig.appConfigClient = appConfigClient
+	appConfig.secretResolver.secretsManagerClient = secretsManagerClient
+	appConfig.secretResolver.ssmClient = ssmClient
 
 	return nil
 }
 
+// refreshResult 统计一次刷新的失败数
+type refreshResult struct {
+	failed    int64
+	throttled int64
+}
+
+// resetLogSampler 替换后台刷新的日志采样，正在进行的刷新仍用旧的
+func (appConfig *EnhancedAppConfig) resetLogSampler() {
+	var sampler *logger.Sampler
+	if appConfig.logSamplingFirst > 0 {
+		sampler = logger.NewSampler(appConfig.logSamplingFirst, appConfig.logSamplingInterval, appConfig.clock)
+	}
+	appConfig.logSampler.Store(sampler)
+}
+
 func (appConfig *EnhancedAppConfig) initRefreshCacheTicker() {
-	cacheRefreshFunc := func() {
-		var ctx context.Context
-		if appConfig.isXRayEnable {
-			_ctx, segment := xray.BeginSegment(context.Background(), "EnhancedAppConfig-CacheRefresh")
-			defer segment.Close(nil)
+	appConfig.resetLogSampler()
 
-			ctx = _ctx
-		} else {
-			ctx = context.Background()
+	cacheRefreshFunc := func(ctx context.Context) {
+		var refreshErr error
+		ctx, span := appConfig.tracer.Start(ctx, "EnhancedAppConfig-CacheRefresh",
+			tracing.Application(appConfig.applicationName), tracing.Environment(appConfig.environmentName))
+		defer func() {
+			span.End(refreshErr)
+		}()
+
+		// AWS AppConfig 不可用时，不要每次刷新都为每个配置打印一遍错误
+		if sampler, _ := appConfig.logSampler.Load().(*logger.Sampler); sampler != nil {
+			ctx = logger.NewContext(ctx, sampler.Wrap(logger.FromContext(ctx, appConfig.logger.Load())))
+			defer sampler.Flush()
 		}
 
-		startTime := time.Now()
-		logger.Debug("start refresh all the caches")
+		startTime := appConfig.clock.Now()
+		appConfig.logCtx(ctx).Debug("start refresh all the caches")
 		var refreshCacheWaitGroup sync.WaitGroup
-		for _, keyI := range appConfig.cache.Keys() {
+		var result refreshResult
+		keys := appConfig.cache.Keys()
+		// 被淘汰的配置如果还有 Value 在用，继续为它刷新
+		refreshKeys := append(keys, appConfig.uncachedListenedKeys(keys)...)
+		for _, key := range refreshKeys {
 			refreshCacheWaitGroup.Add(1)
 			// 多协程并发获取
-			appConfig.refreshKey(ctx, &refreshCacheWaitGroup, keyI)
+			appConfig.refreshKey(ctx, &refreshCacheWaitGroup, key, &result)
 		}
 		refreshCacheWaitGroup.Wait()
-		logger.Debug("end refresh all the caches, cost: ", time.Since(startTime))
+		appConfig.logCtx(ctx).Debug("end refresh all the caches", logger.Duration(appConfig.clock.Since(startTime)))
+		appConfig.recordRefresh(appConfig.clock.Since(startTime))
+
+		// 每次刷新一行，不采样
+		failed, throttled := atomic.LoadInt64(&result.failed), atomic.LoadInt64(&result.throttled)
+		if failed > 0 {
+			refreshErr = fmt.Errorf("refresh [%d] of [%d] caches fail", failed, len(refreshKeys))
+			appConfig.log().Warn("refresh caches fail",
+				logger.Any("failed", failed), logger.Any("throttled", throttled), logger.Any("caches", len(refreshKeys)))
+		}
+
+		// AWS AppConfig 限流时 backoff
+		if throttled > 0 {
+			ticker.Fail(ctx)
+		} else {
+			ticker.Succeed(ctx)
+		}
 	}
 
-	appConfig.cacheRefreshTicker = ticker.New(appConfig.cacheRefreshInterval, cacheRefreshFunc)
+	opts := []ticker.Option{
+		ticker.WithOverlapPolicy(ticker.Skip),
+		ticker.WithJitter(appConfig.cacheRefreshJitter),
+		ticker.WithClock(appConfig.clock),
+		ticker.WithLogger(appConfig.log()),
+	}
+	if appConfig.cacheRefreshSchedule != nil {
+		appConfig.cacheRefreshTicker = ticker.NewWithSchedule(appConfig.cacheRefreshSchedule, cacheRefreshFunc, opts...)
+	} else {
+		appConfig.cacheRefreshTicker = ticker.New(appConfig.cacheRefreshInterval, cacheRefreshFunc, opts...)
+	}
 	appConfig.cacheRefreshTicker.Start()
 }
 
-func (appConfig *EnhancedAppConfig) refreshKey(ctx context.Context, refreshCacheWaitGroup *sync.WaitGroup, keyI interface{}) {
+func (appConfig *EnhancedAppConfig) refreshKey(ctx context.Context, refreshCacheWaitGroup *sync.WaitGroup, key string, result *refreshResult) {
 	go func() {
 		defer func() {
 			refreshCacheWaitGroup.Done()
 			if e := recover(); e != nil {
-				stack := string(debug.Stack())
-				fmt.Println(stack)
-				fmt.Println(e)
+				atomic.AddInt64(&result.failed, 1)
+				appConfig.logCtx(ctx).Error("refresh cache panic", logger.Configuration(key), logger.Any("panic", e), logger.Any("stack", string(debug.Stack())))
 			}
 		}()
 
-		key := keyI.(string)
-		appConfig.Refresh(ctx, key)
+		err := appConfig.refresh(ctx, key)
+		if err != nil {
+			atomic.AddInt64(&result.failed, 1)
+		}
+		if isThrottleError(err) {
+			atomic.AddInt64(&result.throttled, 1)
+		}
 	}()
 
 }
 
 func (appConfig *EnhancedAppConfig) Refresh(ctx context.Context, key string) {
-	logger.Debug("start refresh cache [", key, "]")
-	valueI, found := appConfig.cache.Get(key)
+	_ = appConfig.refresh(ctx, key)
+}
+
+// isThrottleError 判断请求是否因为限流被拒绝
+func isThrottleError(err error) bool {
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && request.IsErrorThrottle(awsErr)
+}
+
+// refresh 返回 AWS AppConfig 的错误，已经记录过日志
+func (appConfig *EnhancedAppConfig) refresh(ctx context.Context, key string) (err error) {
+	ctx, span := appConfig.tracer.Start(ctx, "EnhancedAppConfig-Refresh", tracing.Configuration(key))
+	defer func() {
+		span.End(err)
+	}()
+
+	appConfig.logCtx(ctx).Debug("start refresh cache", logger.Configuration(key))
+	cached, found := appConfig.cache.Peek(key)
 	if !found {
-		return
+		return appConfig.refreshListeners(ctx, key)
 	}
-	if valueI == nil {
-		logger.Warn("refresh cache [", key, "] fail, valueI is nil, cache has been removed")
-		return
+	if cached == nil {
+		appConfig.logCtx(ctx).Warn("refresh cache fail, cached configuration is nil, cache has been removed", logger.Configuration(key))
+		return nil
+	}
+	span.SetAttributes(tracing.Version(aws.StringValue(cached.ClientConfigurationVersion)))
+
+	// 其他进程刚刚获取过
+	if appConfig.refreshFromBackend(ctx, key, cached) {
+		appConfig.logCtx(ctx).Debug("end refresh cache from cache backend", logger.Configuration(key))
+		return nil
 	}
 
-	clientConfigurationVersion := valueI.(*EnhancedConfiguration).ClientConfigurationVersion
+	clientConfigurationVersion := cached.ClientConfigurationVersion
 	configuration, err := appConfig.getConfigurationWithVersion(ctx, key, clientConfigurationVersion)
 	if err != nil {
 		if strings.Contains(err.Error(), "could not be found for account") {
-			logger.Warn("refresh cache [", key, "] fail, configuration profile not exist, ", err)
+			appConfig.logCtx(ctx).Warn("refresh cache fail, configuration profile not exist", logger.Configuration(key), logger.Err(err))
 			// 配置不存在了，删除缓存
 			appConfig.cache.Delete(key)
-			return
+			appConfig.deleteFromBackend(ctx, key)
+			return nil
 		}
-		logger.Error("refresh cache [", key, "] error ", err)
-		return
+		appConfig.logCtx(ctx).Error("refresh cache error", logger.Configuration(key), logger.Err(err))
+		return err
 	}
 
 	if configuration == nil {
-		msg := fmt.Sprintf("get from aws app config failed [%s]", key)
-		logger.Error(msg)
-		return
+		appConfig.logCtx(ctx).Error("get from aws app config failed", logger.Configuration(key))
+		return nil
 	}
 
+	appConfig.setFetched(key, appConfig.clock.Now())
 	if configuration.Content == nil {
-		logger.Debug("cache not change of configuration [", key, "]")
+		appConfig.logCtx(ctx).Debug("cache not change of configuration", logger.Configuration(key))
+		appConfig.rerenderSecrets(ctx, key, cached)
+		appConfig.storeToBackend(ctx, key, cached)
 	} else {
-		logger.Warn("cache change of configuration [", key, "], new configuration version: ", *configuration.ClientConfigurationVersion)
+		span.SetAttributes(tracing.Version(aws.StringValue(configuration.ClientConfigurationVersion)))
+		appConfig.logCtx(ctx).Warn("cache change of configuration", logger.Configuration(key), logger.Version(*configuration.ClientConfigurationVersion))
 		appConfig.cache.Add(key, configuration)
+		appConfig.notifyListeners(key, configuration)
+		appConfig.storeToBackend(ctx, key, configuration)
+	}
+	appConfig.logCtx(ctx).Debug("end refresh cache", logger.Configuration(key))
+	return nil
+}
+
+// refreshListeners 为 listeners 拉取已不在缓存中的配置，新版本不会被缓存
+func (appConfig *EnhancedAppConfig) refreshListeners(ctx context.Context, key string) error {
+	version, found := appConfig.listenedVersion(key)
+	if !found {
+		return nil
 	}
-	logger.Debug("end refresh cache [", key, "]")
+
+	configuration, err := appConfig.getConfigurationWithVersion(ctx, key, version)
+	if err != nil {
+		appConfig.logCtx(ctx).Error("refresh uncached configuration error", logger.Configuration(key), logger.Err(err))
+		return err
+	}
+	if configuration == nil || configuration.Content == nil {
+		appConfig.logCtx(ctx).Debug("uncached configuration not change", logger.Configuration(key))
+		return nil
+	}
+
+	appConfig.logCtx(ctx).Warn("uncached configuration change", logger.Configuration(key), logger.Version(aws.StringValue(configuration.ClientConfigurationVersion)))
+	appConfig.notifyListeners(key, configuration)
+	return nil
 }
 
 func (appConfig *EnhancedAppConfig) GetConfiguration(ctx context.Context, configurationName string) (string, error) {
@@ -230,35 +522,32 @@ func (appConfig *EnhancedAppConfig) GetConfiguration(ctx context.Context, config
 }
 
 func (appConfig *EnhancedAppConfig) GetEnhancedConfiguration(ctx context.Context, configurationName string) (*EnhancedConfiguration, error) {
-	// get from cache if cache is on
-	if appConfig.cache != nil {
-		cacheValue, found := appConfig.cache.Get(configurationName)
-		if found {
-			if cacheValue != nil {
-				configuration := cacheValue.(*EnhancedConfiguration)
-				return configuration, nil
-			}
-			logger.Warn("get configuration from cache, but the value of cache is nil ", configurationName)
-		}
+	if appConfig.cache == nil {
+		return appConfig.GetEnhancedConfigurationIgnoreCache(ctx, configurationName)
 	}
 
-	configuration, err := appConfig.getConfigurationWithVersion(ctx, configurationName, nil)
+	// 同一个配置的并发未命中共享一次请求
+	loaded := false
+	configuration, err := appConfig.cache.GetOrLoad(ctx, configurationName, func(ctx context.Context, key string) (*EnhancedConfiguration, error) {
+		configuration, err := appConfig.loadSharedConfiguration(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		appConfig.logCtx(ctx).Debug("add to cache", logger.Configuration(key), logger.Version(aws.StringValue(configuration.ClientConfigurationVersion)))
+		configuration.IsCache = true
+		loaded = true
+		return configuration, nil
+	})
+	appConfig.recordCacheRead(configurationName, !loaded && err == nil)
 	if err != nil {
 		return nil, err
 	}
 
-	if configuration == nil || configuration.Content == nil {
-		msg := fmt.Sprintf("get from aws app config failed [%s]", configurationName)
-		logger.Error(msg)
-		return nil, errors.New(msg)
+	if !loaded {
+		return configuration, nil
 	}
 
-	// add to cache if cache is on
-	if appConfig.cache != nil {
-		logger.Debug("add to cache ", configurationName)
-		configuration.IsCache = true
-		appConfig.cache.Add(configurationName, configuration)
-	}
+	appConfig.notifyListeners(configurationName, configuration)
 
 	return &EnhancedConfiguration{
 		ClientConfigurationVersion: configuration.ClientConfigurationVersion,
@@ -268,42 +557,53 @@ func (appConfig *EnhancedAppConfig) GetEnhancedConfiguration(ctx context.Context
 }
 
 func (appConfig *EnhancedAppConfig) GetConfigurationIgnoreCache(ctx context.Context, configurationName string) (string, error) {
-	configuration, err := appConfig.getConfigurationWithVersion(ctx, configurationName, nil)
+	configuration, err := appConfig.loadConfiguration(ctx, configurationName)
 	if err != nil {
 		return "", err
 	}
 
-	if configuration == nil || configuration.Content == nil {
-		msg := fmt.Sprintf("get from aws app config failed [%s]", configurationName)
-		logger.Error(msg)
-		return "", errors.New(msg)
+	return *(configuration.Content), nil
+}
+
+func (appConfig *EnhancedAppConfig) GetEnhancedConfigurationIgnoreCache(ctx context.Context, configurationName string) (*EnhancedConfiguration, error) {
+	configuration, err := appConfig.loadConfiguration(ctx, configurationName)
+	if err != nil {
+		return nil, err
 	}
 
-	return *(configuration.Content), err
+	return &EnhancedConfiguration{
+		ClientConfigurationVersion: configuration.ClientConfigurationVersion,
+		Content:                    configuration.Content,
+		IsCache:                    false,
+	}, nil
 }
 
-func (appConfig *EnhancedAppConfig) GetEnhancedConfigurationIgnoreCache(ctx context.Context, configurationName string) (*EnhancedConfiguration, error) {
-	configuration, err := appConfig.getConfigurationWithVersion(ctx, configurationName, nil)
+// loadConfiguration 获取最新的配置，必须有内容
+func (appConfig *EnhancedAppConfig) loadConfiguration(ctx context.Context, configurationName string) (configuration *EnhancedConfiguration, err error) {
+	ctx, span := appConfig.tracer.Start(ctx, "EnhancedAppConfig-Fetch", tracing.Configuration(configurationName))
+	defer func() {
+		span.End(err)
+	}()
+
+	configuration, err = appConfig.getConfigurationWithVersion(ctx, configurationName, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	if configuration == nil || configuration.Content == nil {
 		msg := fmt.Sprintf("get from aws app config failed [%s]", configurationName)
-		logger.Error(msg)
+		appConfig.logCtx(ctx).Error("get from aws app config failed", logger.Configuration(configurationName))
 		return nil, errors.New(msg)
 	}
 
-	return &EnhancedConfiguration{
-		ClientConfigurationVersion: configuration.ClientConfigurationVersion,
-		Content:                    configuration.Content,
-		IsCache:                    false,
-	}, nil
+	span.SetAttributes(tracing.Version(aws.StringValue(configuration.ClientConfigurationVersion)))
+	return configuration, nil
 }
 
 func (appConfig *EnhancedAppConfig) getConfigurationWithVersion(ctx context.Context, configurationName string, configurationVersion *string) (*EnhancedConfiguration, error) {
 	configurationOutput, err := appConfig.getConfiguration(ctx, configurationName, configurationVersion)
 	if err != nil {
+		appConfig.recordFetchError(configurationName, fetchErrorType(err))
 		return nil, err
 	}
 
@@ -315,10 +615,23 @@ func (appConfig *EnhancedAppConfig) getConfigurationWithVersion(ctx context.Cont
 		return &configuration, nil
 	}
 
-	content := string(configurationOutput.Content)
+	rawContent := string(configurationOutput.Content)
+	err = appConfig.schemas.Validate(configurationName, rawContent)
+	if err != nil {
+		appConfig.recordFetchError(configurationName, fetchErrorInvalid)
+		return nil, err
+	}
+
+	content, err := appConfig.renderContent(ctx, configurationName, rawContent)
+	if err != nil {
+		appConfig.recordFetchError(configurationName, fetchErrorOther)
+		return nil, err
+	}
+
 	configuration := EnhancedConfiguration{
 		ClientConfigurationVersion: configurationOutput.ConfigurationVersion,
 		Content:                    &content,
+		rawContent:                 &rawContent,
 	}
 	return &configuration, nil
 }
@@ -333,17 +646,192 @@ func (appConfig *EnhancedAppConfig) getConfiguration(ctx context.Context, config
 	if configurationVersion != nil {
 		input.ClientConfigurationVersion = configurationVersion
 	}
-	now := time.Now()
+	now := appConfig.clock.Now()
 	ctx, cancelFn := context.WithTimeout(ctx, appConfig.timeout)
 	defer cancelFn()
 	configuration, err := appConfig.appConfigClient.GetConfigurationWithContext(ctx, &input)
 	//configuration, err := appConfigClient.GetConfiguration(&input)
+	appConfig.recordFetch(configurationName, appConfig.clock.Since(now))
 	if err == nil {
-		logger.Debug("get configuration from aws app config successfully, name: ", configurationName, ", cost: ", time.Since(now))
+		appConfig.logCtx(ctx).Debug("get configuration from aws app config successfully", logger.Configuration(configurationName), logger.Version(aws.StringValue(configuration.ConfigurationVersion)), logger.Duration(appConfig.clock.Since(now)))
 	}
 	return configuration, err
 }
 
+// renderContent 应用覆盖值并解析 secret 引用
+func (appConfig *EnhancedAppConfig) renderContent(ctx context.Context, configurationName string, rawContent string) (string, error) {
+	content, err := appConfig.applyOverrides(ctx, configurationName, rawContent)
+	if err != nil {
+		return "", err
+	}
+
+	if appConfig.isSecretResolve {
+		content, err = appConfig.secretResolver.resolve(ctx, configurationName, content)
+		if err != nil {
+			return "", err
+		}
+	}
+	return content, nil
+}
+
+// renderRawContent 校验并渲染不是本进程拉取的原始内容
+func (appConfig *EnhancedAppConfig) renderRawContent(ctx context.Context, configurationName string, version string, rawContent string) (*EnhancedConfiguration, error) {
+	err := appConfig.schemas.Validate(configurationName, rawContent)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := appConfig.renderContent(ctx, configurationName, rawContent)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EnhancedConfiguration{
+		ClientConfigurationVersion: aws.String(version),
+		Content:                    &content,
+		rawContent:                 &rawContent,
+	}, nil
+}
+
+// rerenderSecrets 重新渲染引用了 secret 的缓存配置，secret 可能已经轮换
+func (appConfig *EnhancedAppConfig) rerenderSecrets(ctx context.Context, key string, cached *EnhancedConfiguration) {
+	if appConfig.isSecretResolve && cached.rawContent != nil && hasSecretReferences(*cached.rawContent) {
+		appConfig.rerenderCache(ctx, key, cached)
+	}
+}
+
+// rerenderCache 重新渲染缓存配置的原始内容，内容有变化时替换
+func (appConfig *EnhancedAppConfig) rerenderCache(ctx context.Context, key string, cached *EnhancedConfiguration) {
+	if cached.rawContent == nil {
+		return
+	}
+
+	content, err := appConfig.renderContent(ctx, key, *cached.rawContent)
+	if err != nil {
+		appConfig.logCtx(ctx).Error("render cache error", logger.Configuration(key), logger.Err(err))
+		return
+	}
+	if cached.Content != nil && *cached.Content == content {
+		return
+	}
+
+	appConfig.logCtx(ctx).Warn("cache change of configuration, content re-rendered", logger.Configuration(key), logger.Version(aws.StringValue(cached.ClientConfigurationVersion)))
+	configuration := &EnhancedConfiguration{
+		ClientConfigurationVersion: cached.ClientConfigurationVersion,
+		Content:                    &content,
+		IsCache:                    true,
+		rawContent:                 cached.rawContent,
+	}
+	appConfig.cache.Add(key, configuration)
+	appConfig.notifyListeners(key, configuration)
+}
+
+// RegisterSchema 注册配置的 JSON schema，不匹配的版本会被拒绝，不会返回或缓存
+func (appConfig *EnhancedAppConfig) RegisterSchema(configurationName string, jsonSchema string) error {
+	return appConfig.schemas.Register(configurationName, jsonSchema)
+}
+
+func (appConfig *EnhancedAppConfig) addListener(configurationName string, l listener) {
+	appConfig.listenersLock.Lock()
+	defer appConfig.listenersLock.Unlock()
+
+	if appConfig.listeners == nil {
+		appConfig.listeners = map[string][]listener{}
+	}
+	appConfig.listeners[configurationName] = append(appConfig.listeners[configurationName], l)
+}
+
+func (appConfig *EnhancedAppConfig) removeListener(configurationName string, l listener) {
+	appConfig.listenersLock.Lock()
+	defer appConfig.listenersLock.Unlock()
+
+	// 不能原地修改，notifyListeners 可能正在遍历旧的切片
+	var listeners []listener
+	for _, existing := range appConfig.listeners[configurationName] {
+		if existing != l {
+			listeners = append(listeners, existing)
+		}
+	}
+	if len(listeners) == 0 {
+		delete(appConfig.listeners, configurationName)
+	} else {
+		appConfig.listeners[configurationName] = listeners
+	}
+}
+
+// uncachedListenedKeys 返回有 listener 但不在 keys 中的配置
+func (appConfig *EnhancedAppConfig) uncachedListenedKeys(keys []string) []string {
+	cached := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		cached[key] = true
+	}
+
+	appConfig.listenersLock.RLock()
+	defer appConfig.listenersLock.RUnlock()
+
+	var uncached []string
+	for configurationName := range appConfig.listeners {
+		if !cached[configurationName] {
+			uncached = append(uncached, configurationName)
+		}
+	}
+	return uncached
+}
+
+// listenedVersion 返回 listeners 的版本，版本不一致时为 nil 以便都获取最新版本
+func (appConfig *EnhancedAppConfig) listenedVersion(configurationName string) (*string, bool) {
+	appConfig.listenersLock.RLock()
+	listeners := appConfig.listeners[configurationName]
+	appConfig.listenersLock.RUnlock()
+
+	if len(listeners) == 0 {
+		return nil, false
+	}
+	version := listeners[0].version()
+	for _, l := range listeners[1:] {
+		if l.version() != version {
+			return nil, true
+		}
+	}
+	if version == "" {
+		return nil, true
+	}
+	return &version, true
+}
+
+func (appConfig *EnhancedAppConfig) notifyListeners(configurationName string, configuration *EnhancedConfiguration) {
+	appConfig.recordVersion(configurationName, configuration)
+
+	appConfig.listenersLock.RLock()
+	listeners := appConfig.listeners[configurationName]
+	appConfig.listenersLock.RUnlock()
+
+	for _, l := range listeners {
+		l.update(configuration)
+	}
+}
+
+func (appConfig *EnhancedAppConfig) notifyEvicted(configurationName string, reason cache.EvictionReason) {
+	appConfig.listenersLock.RLock()
+	listeners := appConfig.listeners[configurationName]
+	appConfig.listenersLock.RUnlock()
+
+	for _, l := range listeners {
+		l.evicted(reason)
+	}
+}
+
+func (appConfig *EnhancedAppConfig) notifyStopped() {
+	appConfig.listenersLock.RLock()
+	defer appConfig.listenersLock.RUnlock()
+
+	for _, listeners := range appConfig.listeners {
+		for _, l := range listeners {
+			l.stopped()
+		}
+	}
+}
+
 func (appConfig *EnhancedAppConfig) ApplyWithOptions(opts ...Option) error {
 	for _, opt := range opts {
 		err := opt.apply(appConfig)
@@ -351,5 +839,6 @@ func (appConfig *EnhancedAppConfig) ApplyWithOptions(opts ...Option) error {
 			return err
 		}
 	}
+	appConfig.updateLoggers()
 	return nil
 }
@@ -2,14 +2,29 @@ package appconfig
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/cache"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/clock/fakeclock"
 	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/constant"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/logger"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/ticker"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/tracing"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/tracing/oteltracing"
 	appconfigadvance "github.com/hxy1991/aws-sdk-enhanced-go/service/appconfig/advance"
 	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // The 'app1' application with the 'test' environment must have been created in "us-east-1" region
@@ -55,14 +70,14 @@ func TestAppConfig_GetConfiguration(t *testing.T) {
 			appConfig, appConfigAdvance, err := newConfig4Test(tt.fields.applicationName)
 			assert.Nil(t, err)
 
-			// 新增
+			// create
 			isSuccess, err := appConfigAdvance.CreateConfiguration(context.TODO(), tt.args.configurationName, tt.want)
 			assert.True(t, (err != nil) == tt.wantErr, "CreateConfiguration() error = %v, wantErr %v", err, tt.wantErr)
 			assert.True(t, isSuccess, "CreateConfiguration() fail")
 
 			time.Sleep(appConfig.cacheRefreshTicker.Interval() + appConfig.timeout)
 
-			// 查询，从缓存中获取
+			// get, from cache
 			got, err := appConfig.GetConfiguration(context.TODO(), tt.args.configurationName)
 			assert.True(t, (err != nil) == tt.wantErr, "GetConfiguration() error = %v, wantErr %v", err, tt.wantErr)
 			assert.Equal(t, tt.want, got, "GetConfiguration() got = %v, want %v", got, tt.want)
@@ -71,7 +86,7 @@ func TestAppConfig_GetConfiguration(t *testing.T) {
 			assert.True(t, (err != nil) == tt.wantErr, "GetEnhancedConfiguration() error = %v, wantErr %v", err, tt.wantErr)
 			assert.True(t, enhancedConfiguration.IsCache)
 
-			// 删除
+			// delete
 			isSuccess, err = appConfigAdvance.DeleteConfiguration(context.TODO(), tt.args.configurationName)
 			assert.True(t, (err != nil) == tt.wantErr, "DeleteConfiguration() error = %v, wantErr %v", err, tt.wantErr)
 			assert.True(t, isSuccess, "DeleteConfiguration() fail")
@@ -118,17 +133,17 @@ func TestAppConfig_GetConfigurationIgnoreCache(t *testing.T) {
 			assert.Nil(t, err)
 
 			t.Log("configurationName: ", tt.args.configurationName)
-			// 新增
+			// create
 			isSuccess, err := appConfigAdvance.CreateConfiguration(context.TODO(), tt.args.configurationName, tt.want)
 			assert.True(t, (err != nil) == tt.wantErr, "CreateConfiguration() error = %v, wantErr %v", err, tt.wantErr)
 			assert.True(t, isSuccess, "CreateConfiguration() fail")
 
-			// 查询，不从缓存中获取
+			// get, not from cache
 			got, err := appConfig.GetConfigurationIgnoreCache(context.TODO(), tt.args.configurationName)
 			assert.True(t, (err != nil) == tt.wantErr, "GetConfiguration() error = %v, wantErr %v", err, tt.wantErr)
 			assert.Equal(t, tt.want, got, "GetConfiguration() got = %v, want %v", got, tt.want)
 
-			// 删除
+			// delete
 			isSuccess, err = appConfigAdvance.DeleteConfiguration(context.TODO(), tt.args.configurationName)
 			assert.True(t, (err != nil) == tt.wantErr, "DeleteConfiguration() error = %v, wantErr %v", err, tt.wantErr)
 			assert.True(t, isSuccess, "DeleteConfiguration() fail")
@@ -369,3 +384,248 @@ func deleteConfiguration(t *testing.T, configurationName string) {
 
 	assert.True(t, isSuccess, "deleteConfiguration fail")
 }
+
+func TestAppConfig_CacheMaxWeight(t *testing.T) {
+	appConfig := newTestAppConfig(t, "", WithCacheMaxWeight(10))
+
+	small := `{"a": 1}`
+	large := `{"a": 123}`
+	appConfig.cache.Add("small", &EnhancedConfiguration{Content: &small})
+	assert.Equal(t, int64(len(small)), appConfig.CacheStats().Weight)
+
+	appConfig.cache.Add("large", &EnhancedConfiguration{Content: &large})
+	assert.Equal(t, []string{"large"}, appConfig.cache.Keys())
+	assert.Equal(t, int64(len(large)), appConfig.CacheStats().Weight)
+
+	err := WithCacheMaxWeight(5).apply(appConfig)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), appConfig.cache.Len())
+}
+
+func TestAppConfig_NewWithOptionsOnly(t *testing.T) {
+	appConfig := newTestAppConfig(t, "")
+
+	// the cache is not rebuilt under the running client
+	assert.NotNil(t, WithCacheEvictionPolicy(cache.LFU).apply(appConfig))
+	assert.Equal(t, cache.LRU, appConfig.cacheEvictionPolicy)
+	assert.NotNil(t, WithClock(fakeclock.New(time.Now())).apply(appConfig))
+	assert.NotNil(t, WithCacheShards(4).apply(appConfig))
+}
+
+func TestAppConfig_CacheShards(t *testing.T) {
+	appConfig := newTestAppConfig(t, "", WithCacheShards(4))
+	assert.Equal(t, 4, appConfig.cache.Shards())
+
+	assert.NotNil(t, WithCacheShards(0).apply(&EnhancedAppConfig{}))
+}
+
+func TestAppConfig_CacheRefreshIntervalInvalid(t *testing.T) {
+	appConfig := newTestAppConfig(t, "")
+
+	assert.NotNil(t, WithCacheRefreshInterval(0).apply(appConfig))
+	assert.NotNil(t, WithCacheRefreshInterval(-time.Minute).apply(appConfig))
+	assert.Equal(t, time.Minute, appConfig.cacheRefreshInterval)
+	assert.Equal(t, time.Minute, appConfig.cacheRefreshTicker.Interval())
+}
+
+func TestAppConfig_CacheRefreshSchedule(t *testing.T) {
+	clk := fakeclock.New(time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC))
+	schedule, err := ticker.ParseCronInLocation("0 3 * * *", time.UTC)
+	assert.Nil(t, err)
+	appConfig := newTestAppConfig(t, "", WithClock(clk), WithCacheRefreshSchedule(schedule))
+	assert.Eventually(t, func() bool {
+		return appConfig.RefreshStats().Next.Equal(time.Date(2022, 1, 2, 3, 0, 0, 0, time.UTC))
+	}, time.Second, time.Millisecond)
+
+	// the interval does not replace the schedule
+	assert.Nil(t, WithCacheRefreshInterval(time.Minute).apply(appConfig))
+	assert.Equal(t, time.Duration(0), appConfig.cacheRefreshTicker.Interval())
+
+	assert.Nil(t, WithCacheRefreshSchedule(ticker.Every(time.Hour)).apply(appConfig))
+	assert.Eventually(t, func() bool {
+		return appConfig.RefreshStats().Next.Equal(clk.Now().Add(time.Hour))
+	}, time.Second, time.Millisecond)
+}
+
+func TestAppConfig_UpdateRefreshInPlace(t *testing.T) {
+	appConfig := newTestAppConfig(t, "")
+	refreshTicker := appConfig.cacheRefreshTicker
+
+	// the jitter and the log sampling do not replace the running ticker
+	assert.Nil(t, WithCacheRefreshJitter(time.Second*10).apply(appConfig))
+	assert.Nil(t, WithLogSampling(2, time.Minute).apply(appConfig))
+	assert.Same(t, refreshTicker, appConfig.cacheRefreshTicker)
+	assert.Equal(t, time.Second*10, appConfig.cacheRefreshTicker.Jitter())
+	assert.NotNil(t, appConfig.logSampler.Load().(*logger.Sampler))
+
+	assert.Nil(t, WithLogSampling(0, 0).apply(appConfig))
+	assert.Nil(t, appConfig.logSampler.Load().(*logger.Sampler))
+}
+
+func TestAppConfig_IsThrottleError(t *testing.T) {
+	assert.True(t, isThrottleError(awserr.New("ThrottlingException", "Rate exceeded", nil)))
+	assert.True(t, isThrottleError(fmt.Errorf("get configuration: %w", awserr.New("TooManyRequestsException", "", nil))))
+	assert.False(t, isThrottleError(awserr.New("ResourceNotFoundException", "", nil)))
+	assert.False(t, isThrottleError(errors.New("ThrottlingException")))
+	assert.False(t, isThrottleError(nil))
+}
+
+type recordEntry struct {
+	msg    string
+	fields map[string]interface{}
+}
+
+type recordSink struct {
+	mu      sync.Mutex
+	entries []recordEntry
+}
+
+type recordLogger struct {
+	sink   *recordSink
+	fields []logger.Field
+}
+
+func newRecordLogger() *recordLogger {
+	return &recordLogger{sink: &recordSink{}}
+}
+
+func (l *recordLogger) record(msg string, fields []logger.Field) {
+	entry := recordEntry{msg: msg, fields: map[string]interface{}{}}
+	for _, field := range append(l.fields, fields...) {
+		entry.fields[field.Key] = field.Value
+	}
+
+	l.sink.mu.Lock()
+	defer l.sink.mu.Unlock()
+	l.sink.entries = append(l.sink.entries, entry)
+}
+
+func (l *recordLogger) Debug(msg string, fields ...logger.Field) { l.record(msg, fields) }
+func (l *recordLogger) Info(msg string, fields ...logger.Field)  { l.record(msg, fields) }
+func (l *recordLogger) Warn(msg string, fields ...logger.Field)  { l.record(msg, fields) }
+func (l *recordLogger) Error(msg string, fields ...logger.Field) { l.record(msg, fields) }
+
+func (l *recordLogger) With(fields ...logger.Field) logger.Logger {
+	return &recordLogger{sink: l.sink, fields: append(append([]logger.Field{}, l.fields...), fields...)}
+}
+
+func TestAppConfig_WithLogger(t *testing.T) {
+	appConfig := newTestAppConfig(t, "")
+
+	l := newRecordLogger()
+	assert.Nil(t, WithLogger(l).apply(appConfig))
+	assert.Nil(t, WithCacheMaxWeight(5).apply(appConfig))
+
+	// the cache logs with the fields of the client too
+	large := `{"a": 123}`
+	appConfig.cache.Add("large", &EnhancedConfiguration{Content: &large})
+
+	entries := l.sink.entries
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "reset cacheMaxWeight", entries[0].msg)
+	assert.Equal(t, int64(5), entries[0].fields["to"])
+	assert.Equal(t, "the weight of the key exceeds the max weight", entries[1].msg)
+	assert.Equal(t, "large", entries[1].fields["key"])
+	for _, entry := range entries {
+		assert.Equal(t, "app", entry.fields[logger.ApplicationKey])
+		assert.Equal(t, "test", entry.fields[logger.EnvironmentKey])
+		assert.Equal(t, "client", entry.fields[logger.ClientIDKey])
+	}
+}
+
+func TestAppConfig_RefreshLogSampling(t *testing.T) {
+	// AWS AppConfig is down
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"message": "internal error"}`))
+	}))
+	defer server.Close()
+
+	clk := fakeclock.New(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	l := newRecordLogger()
+	appConfig := newTestAppConfig(t, server.URL, WithClock(clk), WithLogSampling(1, time.Minute*3), WithLogger(l))
+
+	for _, key := range []string{"a", "b"} {
+		content := `{}`
+		appConfig.cache.Add(key, &EnhancedConfiguration{ClientConfigurationVersion: aws.String("1"), Content: &content, IsCache: true})
+	}
+
+	count := func(msg string) int {
+		l.sink.mu.Lock()
+		defer l.sink.mu.Unlock()
+		n := 0
+		for _, entry := range l.sink.entries {
+			if entry.msg == msg {
+				n++
+			}
+		}
+		return n
+	}
+	tick := func(runs int64) {
+		clk.WaitForTimers(1)
+		clk.Advance(time.Minute)
+		assert.Eventually(t, func() bool {
+			return appConfig.RefreshStats().Runs == runs
+		}, time.Second, time.Millisecond)
+	}
+
+	// the error of each configuration is logged once in 3 minutes, the
+	// failures are counted at every refresh
+	for runs := int64(1); runs <= 3; runs++ {
+		tick(runs)
+	}
+	assert.Equal(t, 2, count("refresh cache error"))
+	assert.Equal(t, 3, count("refresh caches fail"))
+	assert.Equal(t, 0, count("2 [refresh cache error] entries dropped in last 3m0s"))
+
+	tick(4)
+	assert.Equal(t, 4, count("refresh cache error"))
+	assert.Equal(t, 2, count("2 [refresh cache error] entries dropped in last 3m0s"))
+}
+
+func TestAppConfig_WithTracer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("client_configuration_version") == "" {
+			w.Header().Set("Configuration-Version", "3")
+			_, _ = w.Write([]byte(`{"a": 1}`))
+			return
+		}
+		w.Header().Set("Configuration-Version", "4")
+		_, _ = w.Write([]byte(`{"a": 2}`))
+	}))
+	defer server.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	tracer := oteltracing.New(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test"))
+	appConfig := newTestAppConfig(t, server.URL, WithTracer(tracer))
+
+	_, err := appConfig.GetEnhancedConfiguration(context.Background(), "my-config")
+	assert.Nil(t, err)
+	appConfig.Refresh(context.Background(), "my-config")
+
+	type span struct {
+		name       string
+		parent     string
+		attributes map[string]string
+	}
+	names := map[trace.SpanID]string{}
+	for _, s := range recorder.Ended() {
+		names[s.SpanContext().SpanID()] = s.Name()
+	}
+	var spans []span
+	for _, s := range recorder.Ended() {
+		attributes := map[string]string{}
+		for _, kv := range s.Attributes() {
+			if kv.Key == tracing.ConfigurationKey || kv.Key == tracing.VersionKey {
+				attributes[string(kv.Key)] = kv.Value.AsString()
+			}
+		}
+		spans = append(spans, span{name: s.Name(), parent: names[s.Parent().SpanID()], attributes: attributes})
+	}
+	assert.Equal(t, []span{
+		{name: "AppConfig.GetConfiguration", parent: "EnhancedAppConfig-Fetch", attributes: map[string]string{}},
+		{name: "EnhancedAppConfig-Fetch", attributes: map[string]string{"configuration": "my-config", "version": "3"}},
+		{name: "AppConfig.GetConfiguration", parent: "EnhancedAppConfig-Refresh", attributes: map[string]string{}},
+		{name: "EnhancedAppConfig-Refresh", attributes: map[string]string{"configuration": "my-config", "version": "4"}},
+	}, spans)
+}
@@ -0,0 +1,149 @@
+package appconfig
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/appconfig"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/metrics"
+)
+
+// the types of the fetch errors
+const (
+	fetchErrorThrottled = "throttled"
+	fetchErrorNotFound  = "not_found"
+	fetchErrorTimeout   = "timeout"
+	fetchErrorInvalid   = "invalid"
+	fetchErrorAWS       = "aws"
+	fetchErrorOther     = "other"
+)
+
+// appConfigMetrics are the instruments of a client, labelled with its
+// application, its environment and mostly the configuration.
+type appConfigMetrics struct {
+	fetchDuration   metrics.Histogram
+	fetchErrors     metrics.Counter
+	cacheHits       metrics.Counter
+	cacheMisses     metrics.Counter
+	refreshDuration metrics.Histogram
+	version         metrics.Gauge
+	// unregister removes the staleness function from the provider
+	unregister func()
+}
+
+func newAppConfigMetrics(provider metrics.Provider, staleSeconds metrics.GaugeFunc) *appConfigMetrics {
+	labels := []string{"application", "environment", "configuration"}
+	return &appConfigMetrics{
+		fetchDuration:   provider.Histogram("appconfig_fetch_duration_seconds", "The duration of the requests to AWS AppConfig.", labels...),
+		fetchErrors:     provider.Counter("appconfig_fetch_errors_total", "The number of the configurations which could not be fetched, by type of error.", append(labels, "type")...),
+		cacheHits:       provider.Counter("appconfig_cache_hits_total", "The number of the configurations read from the cache.", labels...),
+		cacheMisses:     provider.Counter("appconfig_cache_misses_total", "The number of the configurations which were not in the cache.", labels...),
+		refreshDuration: provider.Histogram("appconfig_refresh_duration_seconds", "The duration of the refreshes of the cache.", "application", "environment"),
+		version:         provider.Gauge("appconfig_configuration_version", "The version of the cached configurations, when it is a number.", labels...),
+		unregister:      provider.GaugeFunc("appconfig_configuration_stale_seconds", "The seconds since the cached configurations were last fetched.", staleSeconds, labels...),
+	}
+}
+
+// cacheName labels the metrics of the cache of the client.
+func (appConfig *EnhancedAppConfig) cacheName() string {
+	return "appconfig/" + appConfig.applicationName + "/" + appConfig.environmentName
+}
+
+// fetchErrorType classifies an error of AWS AppConfig.
+func fetchErrorType(err error) string {
+	if isThrottleError(err) {
+		return fetchErrorThrottled
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fetchErrorTimeout
+	}
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) {
+		return fetchErrorOther
+	}
+	switch {
+	case awsErr.Code() == appconfig.ErrCodeResourceNotFoundException || strings.Contains(awsErr.Message(), "could not be found for account"):
+		return fetchErrorNotFound
+	case awsErr.Code() == request.CanceledErrorCode && errors.Is(awsErr.OrigErr(), context.DeadlineExceeded):
+		return fetchErrorTimeout
+	}
+	return fetchErrorAWS
+}
+
+// recordFetch records a request to AWS AppConfig.
+func (appConfig *EnhancedAppConfig) recordFetch(configurationName string, duration time.Duration) {
+	appConfig.metrics.fetchDuration.Observe(duration.Seconds(), appConfig.applicationName, appConfig.environmentName, configurationName)
+}
+
+// setFetched records when a cached configuration was fetched from AWS
+// AppConfig, by this process or by another one sharing the cache backend.
+func (appConfig *EnhancedAppConfig) setFetched(configurationName string, fetchedAt time.Time) {
+	appConfig.lastFetchedLock.Lock()
+	defer appConfig.lastFetchedLock.Unlock()
+
+	appConfig.lastFetched[configurationName] = fetchedAt
+}
+
+func (appConfig *EnhancedAppConfig) getFetched(configurationName string) (time.Time, bool) {
+	appConfig.lastFetchedLock.Lock()
+	defer appConfig.lastFetchedLock.Unlock()
+
+	fetchedAt, found := appConfig.lastFetched[configurationName]
+	return fetchedAt, found
+}
+
+func (appConfig *EnhancedAppConfig) recordFetchError(configurationName string, errorType string) {
+	appConfig.metrics.fetchErrors.Add(1, appConfig.applicationName, appConfig.environmentName, configurationName, errorType)
+}
+
+func (appConfig *EnhancedAppConfig) recordCacheRead(configurationName string, hit bool) {
+	if hit {
+		appConfig.metrics.cacheHits.Add(1, appConfig.applicationName, appConfig.environmentName, configurationName)
+	} else {
+		appConfig.metrics.cacheMisses.Add(1, appConfig.applicationName, appConfig.environmentName, configurationName)
+	}
+}
+
+// recordVersion records the version of a new configuration, the versions of
+// the hosted configurations are numbers.
+func (appConfig *EnhancedAppConfig) recordVersion(configurationName string, configuration *EnhancedConfiguration) {
+	if configuration == nil || configuration.ClientConfigurationVersion == nil {
+		return
+	}
+	if version, err := strconv.ParseFloat(*configuration.ClientConfigurationVersion, 64); err == nil {
+		appConfig.metrics.version.Set(version, appConfig.applicationName, appConfig.environmentName, configurationName)
+	}
+}
+
+// recordRefresh records a refresh of the cache.
+func (appConfig *EnhancedAppConfig) recordRefresh(duration time.Duration) {
+	appConfig.metrics.refreshDuration.Observe(duration.Seconds(), appConfig.applicationName, appConfig.environmentName)
+}
+
+// observeStaleSeconds reports how long ago the cached configurations were
+// fetched when the metrics are collected, so that it keeps going up while the
+// refreshes are stalled or backing off.
+func (appConfig *EnhancedAppConfig) observeStaleSeconds(observe func(value float64, labelValues ...string)) {
+	now := appConfig.clock.Now()
+	appConfig.lastFetchedLock.Lock()
+	defer appConfig.lastFetchedLock.Unlock()
+
+	for key, fetched := range appConfig.lastFetched {
+		observe(now.Sub(fetched).Seconds(), appConfig.applicationName, appConfig.environmentName, key)
+	}
+}
+
+// forgetFetched is called when a configuration is no longer cached, its
+// version and staleness are not reported anymore.
+func (appConfig *EnhancedAppConfig) forgetFetched(configurationName string) {
+	appConfig.lastFetchedLock.Lock()
+	defer appConfig.lastFetchedLock.Unlock()
+
+	delete(appConfig.lastFetched, configurationName)
+	appConfig.metrics.version.Delete(appConfig.applicationName, appConfig.environmentName, configurationName)
+}
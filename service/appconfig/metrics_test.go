@@ -0,0 +1,244 @@
+package appconfig
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/appconfig"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/clock/fakeclock"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordProvider records the last value of the gauges, the sums of the
+// counters and the number of observations of the histograms.
+type recordProvider struct {
+	mu         sync.Mutex
+	values     map[string]float64
+	gaugeFuncs map[string]metrics.GaugeFunc
+}
+
+func newRecordProvider() *recordProvider {
+	return &recordProvider{values: map[string]float64{}, gaugeFuncs: map[string]metrics.GaugeFunc{}}
+}
+
+// collect records the values reported by the gauge functions, in place of
+// the ones of the last collection.
+func (p *recordProvider) collect() {
+	p.mu.Lock()
+	gaugeFuncs := make(map[string]metrics.GaugeFunc, len(p.gaugeFuncs))
+	for name, f := range p.gaugeFuncs {
+		gaugeFuncs[name] = f
+		for key := range p.values {
+			if strings.HasPrefix(key, name+"{") {
+				delete(p.values, key)
+			}
+		}
+	}
+	p.mu.Unlock()
+
+	for name, f := range gaugeFuncs {
+		name := name
+		f(func(value float64, labelValues ...string) {
+			p.record(name, labelValues, func(float64) float64 { return value })
+		})
+	}
+}
+
+func (p *recordProvider) record(name string, labelValues []string, update func(old float64) float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := name + "{" + strings.Join(labelValues, ",") + "}"
+	p.values[key] = update(p.values[key])
+}
+
+func (p *recordProvider) delete(name string, labelValues []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.values, name+"{"+strings.Join(labelValues, ",")+"}")
+}
+
+func (p *recordProvider) has(key string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, found := p.values[key]
+	return found
+}
+
+func (p *recordProvider) get(key string) float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.values[key]
+}
+
+type recordInstrument struct {
+	provider *recordProvider
+	name     string
+}
+
+func (i recordInstrument) Add(delta float64, labelValues ...string) {
+	i.provider.record(i.name, labelValues, func(old float64) float64 { return old + delta })
+}
+
+func (i recordInstrument) Set(value float64, labelValues ...string) {
+	i.provider.record(i.name, labelValues, func(float64) float64 { return value })
+}
+
+func (i recordInstrument) Delete(labelValues ...string) {
+	i.provider.delete(i.name, labelValues)
+}
+
+func (i recordInstrument) Observe(_ float64, labelValues ...string) {
+	i.provider.record(i.name, labelValues, func(old float64) float64 { return old + 1 })
+}
+
+func (p *recordProvider) Counter(name string, _ string, _ ...string) metrics.Counter {
+	return recordInstrument{provider: p, name: name}
+}
+
+func (p *recordProvider) Gauge(name string, _ string, _ ...string) metrics.Gauge {
+	return recordInstrument{provider: p, name: name}
+}
+
+func (p *recordProvider) Histogram(name string, _ string, _ ...string) metrics.Histogram {
+	return recordInstrument{provider: p, name: name}
+}
+
+func (p *recordProvider) GaugeFunc(name string, _ string, f metrics.GaugeFunc, _ ...string) func() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.gaugeFuncs[name] = f
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		delete(p.gaugeFuncs, name)
+	}
+}
+
+func TestMetrics_GetEnhancedConfiguration(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/configurations/found"):
+			w.Header().Set("Configuration-Version", "3")
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"a": 1}`))
+		case strings.HasSuffix(r.URL.Path, "/configurations/throttled"):
+			w.Header().Set("X-Amzn-Errortype", "ThrottlingException")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"message": "rate exceeded"}`))
+		default:
+			w.Header().Set("X-Amzn-Errortype", appconfig.ErrCodeResourceNotFoundException)
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message": "not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	clk := fakeclock.New(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	provider := newRecordProvider()
+	appConfig := newTestAppConfig(t, server.URL, WithClock(clk), WithMetrics(provider))
+	var err error
+
+	for i := 0; i < 2; i++ {
+		_, err = appConfig.GetEnhancedConfiguration(context.Background(), "found")
+		assert.Nil(t, err)
+	}
+	_, err = appConfig.GetEnhancedConfiguration(context.Background(), "missing")
+	assert.NotNil(t, err)
+	_, err = appConfig.GetEnhancedConfiguration(context.Background(), "throttled")
+	assert.NotNil(t, err)
+
+	assert.Equal(t, float64(1), provider.get("appconfig_cache_misses_total{app,test,found}"))
+	assert.Equal(t, float64(1), provider.get("appconfig_cache_hits_total{app,test,found}"))
+	assert.Equal(t, float64(1), provider.get("appconfig_fetch_duration_seconds{app,test,found}"))
+	assert.Equal(t, float64(3), provider.get("appconfig_configuration_version{app,test,found}"))
+	assert.Equal(t, float64(1), provider.get("appconfig_fetch_errors_total{app,test,missing,not_found}"))
+	assert.Equal(t, float64(1), provider.get("appconfig_fetch_errors_total{app,test,throttled,throttled}"))
+	assert.Equal(t, float64(1), provider.get("cache_size{appconfig/app/test}"))
+
+	// the configuration is stale until it is fetched again, whether the
+	// refreshes run or not
+	clk.Advance(time.Second * 30)
+	provider.collect()
+	assert.Equal(t, float64(30), provider.get("appconfig_configuration_stale_seconds{app,test,found}"))
+	clk.Advance(time.Second * 30)
+	provider.collect()
+	assert.Equal(t, float64(60), provider.get("appconfig_configuration_stale_seconds{app,test,found}"))
+
+	_ = appConfig.refresh(context.Background(), "found")
+	appConfig.recordRefresh(time.Second)
+	provider.collect()
+	assert.Equal(t, float64(0), provider.get("appconfig_configuration_stale_seconds{app,test,found}"))
+	assert.Equal(t, float64(1), provider.get("appconfig_refresh_duration_seconds{app,test}"))
+	assert.Equal(t, float64(2), provider.get("appconfig_fetch_duration_seconds{app,test,found}"))
+}
+
+func TestMetrics_Evicted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Configuration-Version", "3")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"a": 1}`))
+	}))
+	defer server.Close()
+
+	provider := newRecordProvider()
+	appConfig := newTestAppConfig(t, server.URL, WithMetrics(provider))
+
+	// the configurations not cached are not fetched for the staleness
+	_, err := appConfig.GetEnhancedConfigurationIgnoreCache(context.Background(), "found")
+	assert.Nil(t, err)
+	_, found := appConfig.getFetched("found")
+	assert.False(t, found)
+
+	_, err = appConfig.GetEnhancedConfiguration(context.Background(), "found")
+	assert.Nil(t, err)
+	_, found = appConfig.getFetched("found")
+	assert.True(t, found)
+	provider.collect()
+	assert.True(t, provider.has("appconfig_configuration_version{app,test,found}"))
+	assert.True(t, provider.has("appconfig_configuration_stale_seconds{app,test,found}"))
+
+	appConfig.cache.Delete("found")
+	provider.collect()
+	_, found = appConfig.getFetched("found")
+	assert.False(t, found)
+	assert.False(t, provider.has("appconfig_configuration_version{app,test,found}"))
+	assert.False(t, provider.has("appconfig_configuration_stale_seconds{app,test,found}"))
+
+	// the function of the replaced provider is unregistered
+	assert.Nil(t, WithMetrics(nil).apply(appConfig))
+	provider.mu.Lock()
+	assert.Empty(t, provider.gaugeFuncs)
+	provider.mu.Unlock()
+}
+
+func TestMetrics_FetchErrorType(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "context", err: context.DeadlineExceeded, want: fetchErrorTimeout},
+		{name: "canceled", err: awserr.New(request.CanceledErrorCode, "request context canceled", context.DeadlineExceeded), want: fetchErrorTimeout},
+		{name: "not found", err: awserr.New("BadRequestException", "Configuration profile a could not be found for account 1", nil), want: fetchErrorNotFound},
+		{name: "aws", err: awserr.New(appconfig.ErrCodeInternalServerException, "internal error", nil), want: fetchErrorAWS},
+		{name: "other", err: context.Canceled, want: fetchErrorOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, fetchErrorType(tt.err))
+		})
+	}
+}
@@ -1,9 +1,17 @@
 package appconfig
 
 import (
+	"errors"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/cache"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/clock"
 	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/logger"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/metrics"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/ticker"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/tracing"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/tracing/xraytracing"
 )
 
 type Option interface {
@@ -29,6 +37,19 @@ func WithRegionName(regionName string) Option {
 	})
 }
 
+// WithSession 使用 sess 创建 AWS 客户端，例如使用其他的凭证或 endpoint
+func WithSession(sess *session.Session) Option {
+	return optionFunc(func(appConfig *EnhancedAppConfig) error {
+		appConfig.session = sess
+
+		// 已经创建的 AWS 客户端要换成新的 session
+		if appConfig.appConfigClient != nil {
+			return appConfig.initAppConfigClient()
+		}
+		return nil
+	})
+}
+
 func WithApplicationName(applicationName string) Option {
 	return optionFunc(func(appConfig *EnhancedAppConfig) error {
 		appConfig.applicationName = applicationName
@@ -53,6 +74,10 @@ func WithClientId(clientId string) Option {
 func WithIsCache(isCache bool) Option {
 	return optionFunc(func(appConfig *EnhancedAppConfig) error {
 		appConfig.isCache = isCache
+		if !appConfig.created {
+			// NewWithOptions 在所有的 option 之后创建缓存
+			return nil
+		}
 
 		if appConfig.cache != nil {
 			if !isCache {
@@ -60,7 +85,8 @@ func WithIsCache(isCache bool) Option {
 				appConfig.cache = nil
 				appConfig.cacheRefreshTicker.Stop()
 				appConfig.cacheRefreshTicker = nil
-				logger.Warn("cacheRefreshTicker has been stopped and cache has been shut down")
+				appConfig.log().Warn("cacheRefreshTicker has been stopped and cache has been shut down")
+				appConfig.notifyStopped()
 			}
 		} else {
 			if isCache {
@@ -87,22 +113,154 @@ func WithCacheLimit(cacheLimit int64) Option {
 		if appConfig.cache != nil {
 			if cacheLimit != 0 {
 				oldCacheLimit := appConfig.cache.UpdateCacheLimit(cacheLimit)
-				logger.Warn("reset cacheLimit from ", oldCacheLimit, " to ", cacheLimit)
+				appConfig.log().Warn("reset cacheLimit", logger.Any("from", oldCacheLimit), logger.Any("to", cacheLimit))
 			}
 		}
 		return nil
 	})
 }
 
+// WithCacheMaxWeight 限制缓存的配置内容的总字节数，0 表示不限制
+func WithCacheMaxWeight(maxWeight int64) Option {
+	return optionFunc(func(appConfig *EnhancedAppConfig) error {
+		appConfig.cacheMaxWeight = maxWeight
+
+		if appConfig.cache != nil {
+			oldMaxWeight := appConfig.cache.UpdateMaxWeight(maxWeight)
+			appConfig.log().Warn("reset cacheMaxWeight", logger.Any("from", oldMaxWeight), logger.Any("to", maxWeight))
+		}
+		return nil
+	})
+}
+
+// WithCacheEvictionPolicy 设置缓存的淘汰策略，默认 LRU，只能在 NewWithOptions 中设置
+func WithCacheEvictionPolicy(evictionPolicy cache.EvictionPolicy) Option {
+	return optionFunc(func(appConfig *EnhancedAppConfig) error {
+		if appConfig.created {
+			return errors.New("cache eviction policy can only be set by NewWithOptions")
+		}
+		appConfig.cacheEvictionPolicy = evictionPolicy
+		return nil
+	})
+}
+
+// WithCacheShards 将缓存分为 n 个分片，默认为 cache.DefaultShards，只能在 NewWithOptions 中设置
+func WithCacheShards(n int) Option {
+	return optionFunc(func(appConfig *EnhancedAppConfig) error {
+		if appConfig.created {
+			return errors.New("cache shards can only be set by NewWithOptions")
+		}
+		if n < 1 {
+			return errors.New("cache shards must be positive")
+		}
+		appConfig.cacheShards = n
+		return nil
+	})
+}
+
+// WithCacheBackend 与使用同一个 backend 的其他进程共享拉取到的配置，
+// 在缓存刷新间隔内被其他进程拉取过的配置直接从 backend 获取
+func WithCacheBackend(backend cache.Backend) Option {
+	return optionFunc(func(appConfig *EnhancedAppConfig) error {
+		appConfig.cacheBackend = backend
+		return nil
+	})
+}
+
+// WithClock 替换 time 包的时钟，决定缓存何时刷新以及何时过期，只能在 NewWithOptions 中设置
+func WithClock(c clock.Clock) Option {
+	return optionFunc(func(appConfig *EnhancedAppConfig) error {
+		if appConfig.created {
+			return errors.New("clock can only be set by NewWithOptions")
+		}
+		appConfig.clock = c
+		appConfig.secretResolver.secrets = newSecretResolver(appConfig.secretResolver.ttl, c).secrets
+		return nil
+	})
+}
+
+// WithLogger 设置客户端、缓存以及刷新 ticker 的 logger
+func WithLogger(l logger.Logger) Option {
+	return optionFunc(func(appConfig *EnhancedAppConfig) error {
+		appConfig.logger.Set(l)
+		appConfig.updateLoggers()
+		return nil
+	})
+}
+
 func WithCacheRefreshInterval(cacheRefreshInterval time.Duration) Option {
 	return optionFunc(func(appConfig *EnhancedAppConfig) error {
+		if cacheRefreshInterval <= 0 {
+			return errors.New("cache refresh interval must be positive")
+		}
 		appConfig.cacheRefreshInterval = cacheRefreshInterval
 
+		// 设置了 cacheRefreshSchedule 时由它决定何时刷新
+		if appConfig.cache != nil && appConfig.cacheRefreshSchedule == nil {
+			oldInterval := appConfig.cacheRefreshTicker.Reset(cacheRefreshInterval)
+			appConfig.log().Warn("reset refresh cache ticker interval", logger.Any("from", oldInterval), logger.Any("to", cacheRefreshInterval))
+		}
+		return nil
+	})
+}
+
+// WithCacheRefreshSchedule 按 schedule 刷新缓存，代替固定的缓存刷新间隔
+func WithCacheRefreshSchedule(schedule ticker.Schedule) Option {
+	return optionFunc(func(appConfig *EnhancedAppConfig) error {
+		appConfig.cacheRefreshSchedule = schedule
+
 		if appConfig.cache != nil {
-			if cacheRefreshInterval != 0 {
-				oldInterval := appConfig.cacheRefreshTicker.Reset(cacheRefreshInterval)
-				logger.Warn("reset refresh cache ticker interval from ", oldInterval, " to ", cacheRefreshInterval)
-			}
+			appConfig.cacheRefreshTicker.SetSchedule(schedule)
+			appConfig.log().Warn("reset refresh cache ticker schedule")
+		}
+		return nil
+	})
+}
+
+// WithCacheRefreshJitter 为每次缓存刷新加上不超过 jitter 的随机延迟
+func WithCacheRefreshJitter(jitter time.Duration) Option {
+	return optionFunc(func(appConfig *EnhancedAppConfig) error {
+		oldJitter := appConfig.cacheRefreshJitter
+		appConfig.cacheRefreshJitter = jitter
+
+		if appConfig.cache != nil && oldJitter != jitter {
+			appConfig.cacheRefreshTicker.SetJitter(jitter)
+			appConfig.log().Warn("reset refresh cache jitter", logger.Any("from", oldJitter), logger.Any("to", jitter))
+		}
+		return nil
+	})
+}
+
+// WithLogSampling 对后台刷新的日志采样，每个配置在 interval 内最多记录 first 条，0 表示不采样
+func WithLogSampling(first int, interval time.Duration) Option {
+	return optionFunc(func(appConfig *EnhancedAppConfig) error {
+		if first > 0 && interval <= 0 {
+			return errors.New("log sampling interval must be positive")
+		}
+		oldFirst, oldInterval := appConfig.logSamplingFirst, appConfig.logSamplingInterval
+		appConfig.logSamplingFirst = first
+		appConfig.logSamplingInterval = interval
+
+		if appConfig.cache != nil && (oldFirst != first || oldInterval != interval) {
+			appConfig.resetLogSampler()
+			appConfig.log().Info("reset log sampling", logger.Any("first", first), logger.Any("interval", interval))
+		}
+		return nil
+	})
+}
+
+// WithMetrics 将客户端和缓存的指标记录到 provider，nil 表示不记录
+func WithMetrics(provider metrics.Provider) Option {
+	return optionFunc(func(appConfig *EnhancedAppConfig) error {
+		appConfig.metricsProvider = provider
+		if provider == nil {
+			provider = metrics.Nop()
+		}
+		appConfig.metrics.unregister()
+		appConfig.metrics = newAppConfigMetrics(provider, appConfig.observeStaleSeconds)
+
+		if appConfig.cache != nil {
+			appConfig.cache.SetMetrics(appConfig.metricsProvider, appConfig.cacheName())
 		}
 		return nil
 	})
@@ -114,17 +272,30 @@ func WithTimeout(timeout time.Duration) Option {
 		appConfig.timeout = timeout
 
 		if oldTime != 0 {
-			logger.Info("reset timeout from ", oldTime, " to ", timeout)
+			appConfig.log().Info("reset timeout", logger.Any("from", oldTime), logger.Any("to", timeout))
 		}
 
 		return nil
 	})
 }
 
+// WithXRayEnable 即 WithTracer(xraytracing.New())，false 关闭追踪
 func WithXRayEnable(isXRayEnable bool) Option {
+	if isXRayEnable {
+		return WithTracer(xraytracing.New())
+	}
+	return WithTracer(nil)
+}
+
+// WithTracer 追踪配置的拉取、缓存刷新以及 AWS 请求，nil 关闭追踪
+func WithTracer(tracer tracing.Tracer) Option {
 	return optionFunc(func(appConfig *EnhancedAppConfig) error {
-		appConfig.isXRayEnable = isXRayEnable
+		if tracer == nil {
+			tracer = tracing.Nop()
+		}
+		appConfig.tracer = tracer
 
+		// AWS 客户端要重新创建才能换成新的 tracer
 		err := appConfig.initAppConfigClient()
 		if err != nil {
 			return err
@@ -133,3 +304,44 @@ func WithXRayEnable(isXRayEnable bool) Option {
 		return nil
 	})
 }
+
+func WithSchema(configurationName string, jsonSchema string) Option {
+	return optionFunc(func(appConfig *EnhancedAppConfig) error {
+		return appConfig.RegisterSchema(configurationName, jsonSchema)
+	})
+}
+
+// WithOverrides 设置显式的覆盖值，优先于 APPCONFIG_OVERRIDE_ 环境变量和 AWS AppConfig 的内容
+func WithOverrides(overrides map[string]string) Option {
+	return optionFunc(func(appConfig *EnhancedAppConfig) error {
+		newOverrides := Overrides{}
+		for key, value := range overrides {
+			newOverrides[key] = value
+		}
+
+		appConfig.overridesLock.Lock()
+		appConfig.overrides = newOverrides
+		appConfig.overridesLock.Unlock()
+
+		appConfig.reapplyOverrides()
+		return nil
+	})
+}
+
+// WithIsSecretResolve 开启或关闭配置内容中 ${secretsmanager:...} 和 ${ssm:...} 引用的解析
+func WithIsSecretResolve(isSecretResolve bool) Option {
+	return optionFunc(func(appConfig *EnhancedAppConfig) error {
+		appConfig.isSecretResolve = isSecretResolve
+		return nil
+	})
+}
+
+// WithSecretCacheTTL 设置解析出的 secret 的缓存时间
+func WithSecretCacheTTL(secretCacheTTL time.Duration) Option {
+	return optionFunc(func(appConfig *EnhancedAppConfig) error {
+		oldTTL := appConfig.secretResolver.ttl
+		appConfig.secretResolver.ttl = secretCacheTTL
+		appConfig.log().Info("reset secretCacheTTL", logger.Any("from", oldTTL), logger.Any("to", secretCacheTTL))
+		return nil
+	})
+}
@@ -0,0 +1,210 @@
+package appconfig
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/constant"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/logger"
+)
+
+const (
+	// overridePathSeparator separates the configuration name from the key path
+	// in the keys of Overrides, e.g. "my-config#database.host"
+	overridePathSeparator = "#"
+	// envOverridePathSeparator separates the configuration name and the keys in
+	// the names of the override env variables
+	envOverridePathSeparator = "__"
+)
+
+// Overrides replace a whole configuration, or a key of its JSON content when
+// the key is "<configuration name>#<key.path>". It is a flag.Value.
+type Overrides map[string]string
+
+func (o Overrides) String() string {
+	keys := make([]string, 0, len(o))
+	for key := range o {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+o[key])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (o *Overrides) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return fmt.Errorf("invalid override [%s], expected <configuration name>[#<key path>]=<value>", s)
+	}
+	if *o == nil {
+		*o = Overrides{}
+	}
+	(*o)[s[:i]] = s[i+1:]
+	return nil
+}
+
+// loadEnvOverrides returns the override env variables keyed by the part after
+// constant.OverrideEnvPrefix.
+func loadEnvOverrides() map[string]string {
+	envOverrides := map[string]string{}
+	for _, env := range os.Environ() {
+		if !strings.HasPrefix(env, constant.OverrideEnvPrefix) {
+			continue
+		}
+		i := strings.Index(env, "=")
+		if i < 0 {
+			continue
+		}
+		envOverrides[env[len(constant.OverrideEnvPrefix):i]] = env[i+1:]
+	}
+	return envOverrides
+}
+
+// envOverrideName converts a configuration name or a key to its form in the
+// override env variable names: upper case with "_" for the other characters.
+func envOverrideName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		}
+		return '_'
+	}, name)
+}
+
+// applyOverrides applies the explicit overrides and then the env overrides
+// that are not set explicitly.
+func (appConfig *EnhancedAppConfig) applyOverrides(ctx context.Context, configurationName string, content string) (string, error) {
+	appConfig.overridesLock.RLock()
+	defer appConfig.overridesLock.RUnlock()
+
+	if len(appConfig.overrides) == 0 && len(appConfig.envOverrides) == 0 {
+		return content, nil
+	}
+
+	envName := envOverrideName(configurationName)
+	if value, found := appConfig.overrides[configurationName]; found {
+		appConfig.logCtx(ctx).Info("content of configuration is overridden", logger.Configuration(configurationName))
+		content = value
+	} else if value, found := appConfig.envOverrides[envName]; found {
+		appConfig.logCtx(ctx).Info("content of configuration is overridden by env", logger.Configuration(configurationName), logger.Any("env", constant.OverrideEnvPrefix+envName))
+		content = value
+	}
+
+	type pathOverride struct {
+		segments []string
+		value    string
+		// env keys are upper case, they match the keys of the content after envOverrideName
+		isEnv bool
+	}
+	var pathOverrides []pathOverride
+	explicitPaths := map[string]bool{}
+	for key, value := range appConfig.overrides {
+		if strings.HasPrefix(key, configurationName+overridePathSeparator) {
+			path := key[len(configurationName)+len(overridePathSeparator):]
+			explicitPaths[envOverrideName(path)] = true
+			pathOverrides = append(pathOverrides, pathOverride{segments: strings.Split(path, "."), value: value})
+		}
+	}
+	for key, value := range appConfig.envOverrides {
+		if strings.HasPrefix(key, envName+envOverridePathSeparator) {
+			segments := strings.Split(key[len(envName)+len(envOverridePathSeparator):], envOverridePathSeparator)
+			if explicitPaths[strings.Join(segments, "_")] {
+				continue
+			}
+			pathOverrides = append(pathOverrides, pathOverride{segments: segments, value: value, isEnv: true})
+		}
+	}
+	if len(pathOverrides) == 0 {
+		return content, nil
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(content))
+	decoder.UseNumber()
+	var document map[string]interface{}
+	err := decoder.Decode(&document)
+	if err != nil {
+		return "", fmt.Errorf("override keys of configuration [%s] fail, content must be a JSON object: %w", configurationName, err)
+	}
+	if document == nil {
+		document = map[string]interface{}{}
+	}
+
+	for _, override := range pathOverrides {
+		appConfig.logCtx(ctx).Info("key of configuration is overridden", logger.Configuration(configurationName), logger.Any("key", strings.Join(override.segments, ".")))
+		setPath(document, override.segments, parseOverrideValue(override.value), override.isEnv)
+	}
+
+	b, err := json.Marshal(document)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// reapplyOverrides applies the current overrides to the cached configurations.
+func (appConfig *EnhancedAppConfig) reapplyOverrides() {
+	if appConfig.cache == nil {
+		return
+	}
+
+	for _, key := range appConfig.cache.Keys() {
+		cached, found := appConfig.cache.Peek(key)
+		if !found || cached == nil {
+			continue
+		}
+		appConfig.rerenderCache(context.Background(), key, cached)
+	}
+}
+
+func setPath(document map[string]interface{}, segments []string, value interface{}, isEnv bool) {
+	current := document
+	for i, segment := range segments {
+		key := segment
+		if isEnv {
+			key = matchEnvKey(current, segment)
+		}
+		if i == len(segments)-1 {
+			current[key] = value
+			return
+		}
+		next, ok := current[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			current[key] = next
+		}
+		current = next
+	}
+}
+
+// matchEnvKey returns the existing key of the object matching the env segment
+// ignoring "_", so MAX_CONNS matches maxConns, or the lower case segment when
+// there is none.
+func matchEnvKey(object map[string]interface{}, segment string) string {
+	for key := range object {
+		if strings.ReplaceAll(envOverrideName(key), "_", "") == strings.ReplaceAll(segment, "_", "") {
+			return key
+		}
+	}
+	return strings.ToLower(segment)
+}
+
+func parseOverrideValue(value string) interface{} {
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err == nil && !decoder.More() {
+		return v
+	}
+	return value
+}
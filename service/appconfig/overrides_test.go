@@ -0,0 +1,93 @@
+package appconfig
+
+import (
+	"context"
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverrides_Apply(t *testing.T) {
+	tests := []struct {
+		name         string
+		overrides    Overrides
+		envOverrides map[string]string
+		content      string
+		want         string
+	}{
+		{
+			name:    "no overrides",
+			content: `{"a": 1}`,
+			want:    `{"a": 1}`,
+		},
+		{
+			name:      "whole content",
+			overrides: Overrides{"my-config": "pinned"},
+			content:   `{"a": 1}`,
+			want:      "pinned",
+		},
+		{
+			name:         "whole content from env",
+			envOverrides: map[string]string{"MY_CONFIG": `{"b": 2}`},
+			content:      `{"a": 1}`,
+			want:         `{"b": 2}`,
+		},
+		{
+			name:         "explicit before env",
+			overrides:    Overrides{"my-config": "explicit"},
+			envOverrides: map[string]string{"MY_CONFIG": "env"},
+			content:      `{"a": 1}`,
+			want:         "explicit",
+		},
+		{
+			name:      "key path",
+			overrides: Overrides{"my-config#database.host": "localhost", "my-config#database.port": "5433", "other#a": "2"},
+			content:   `{"a": 1, "database": {"host": "db", "port": 5432}}`,
+			want:      `{"a":1,"database":{"host":"localhost","port":5433}}`,
+		},
+		{
+			name:         "key path from env",
+			envOverrides: map[string]string{"MY_CONFIG__DATABASE__MAX_CONNS": "10", "MY_CONFIG__NEW": "true"},
+			content:      `{"a": 1, "database": {"maxConns": 5}}`,
+			want:         `{"a":1,"database":{"maxConns":10},"new":true}`,
+		},
+		{
+			name:         "explicit key path before env",
+			overrides:    Overrides{"my-config#database.host": "explicit"},
+			envOverrides: map[string]string{"MY_CONFIG__DATABASE__HOST": "env"},
+			content:      `{"database": {"host": "db"}}`,
+			want:         `{"database":{"host":"explicit"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appConfig := newTestAppConfig(t, "", WithOverrides(tt.overrides))
+			appConfig.envOverrides = tt.envOverrides
+			got, err := appConfig.applyOverrides(context.Background(), "my-config", tt.content)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestOverrides_Flag(t *testing.T) {
+	var overrides Overrides
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	flagSet.Var(&overrides, "appconfig-override", "")
+
+	err := flagSet.Parse([]string{
+		"-appconfig-override", "my-config#database.host=localhost",
+		"-appconfig-override", "other=a=b",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, Overrides{"my-config#database.host": "localhost", "other": "a=b"}, overrides)
+	assert.Equal(t, "my-config#database.host=localhost,other=a=b", overrides.String())
+
+	assert.NotNil(t, overrides.Set("invalid"))
+}
+
+func TestOverrides_EnvOverrideName(t *testing.T) {
+	assert.Equal(t, "MY_CONFIG_V2", envOverrideName("my-config.v2"))
+	assert.Equal(t, "MAX_CONNS", envOverrideName("max-conns"))
+}
@@ -0,0 +1,171 @@
+package appconfig
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/cache"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/clock"
+)
+
+const (
+	secretsManagerSource = "secretsmanager"
+	ssmSource            = "ssm"
+	// secretFieldSeparator separates the secret id from the JSON field of the secret
+	secretFieldSeparator = "#"
+	// the max number of resolved secrets that can be cached
+	secretCacheLimit = int64(1000)
+)
+
+// secretReferencePattern matches ${secretsmanager:<secret id>[#<field>]} and ${ssm:<parameter name>}
+var secretReferencePattern = regexp.MustCompile(`\$\{(secretsmanager|ssm):([^}]+)}`)
+
+// secretResolver replaces the secret references in the content of the
+// configurations, the resolved values are cached for ttl.
+// The resolved values must never be logged.
+type secretResolver struct {
+	secretsManagerClient secretsmanageriface.SecretsManagerAPI
+	ssmClient            ssmiface.SSMAPI
+	ttl                  time.Duration
+
+	// secrets caches the resolved values by reference
+	secrets *cache.Cache[string, string]
+}
+
+func newSecretResolver(ttl time.Duration, clk clock.Clock) *secretResolver {
+	return &secretResolver{
+		ttl:     ttl,
+		secrets: cache.New[string, string](secretCacheLimit, cache.WithClock(clk)),
+	}
+}
+
+func hasSecretReferences(content string) bool {
+	return secretReferencePattern.MatchString(content)
+}
+
+// resolve replaces the secret references, the values are escaped when the
+// content is JSON because the references are expected inside JSON strings.
+func (r *secretResolver) resolve(ctx context.Context, configurationName string, content string) (string, error) {
+	if !hasSecretReferences(content) {
+		return content, nil
+	}
+
+	isJSON := json.Valid([]byte(content))
+
+	var resolveErr error
+	resolved := secretReferencePattern.ReplaceAllStringFunc(content, func(reference string) string {
+		if resolveErr != nil {
+			return reference
+		}
+
+		value, err := r.get(ctx, reference)
+		if err != nil {
+			resolveErr = fmt.Errorf("resolve secret reference %s of configuration [%s] fail: %w", reference, configurationName, err)
+			return reference
+		}
+
+		if isJSON {
+			b, _ := json.Marshal(value)
+			return string(b[1 : len(b)-1])
+		}
+		return value
+	})
+	if resolveErr != nil {
+		return "", resolveErr
+	}
+	return resolved, nil
+}
+
+func (r *secretResolver) get(ctx context.Context, reference string) (string, error) {
+	if value, found := r.secrets.Get(reference); found {
+		return value, nil
+	}
+
+	match := secretReferencePattern.FindStringSubmatch(reference)
+	var value string
+	var err error
+	switch match[1] {
+	case secretsManagerSource:
+		value, err = r.getSecretValue(ctx, match[2])
+	case ssmSource:
+		value, err = r.getParameter(ctx, match[2])
+	}
+	if err != nil {
+		return "", err
+	}
+
+	r.secrets.AddWithTTL(reference, value, r.ttl)
+
+	return value, nil
+}
+
+func (r *secretResolver) getSecretValue(ctx context.Context, secret string) (string, error) {
+	if r.secretsManagerClient == nil {
+		return "", fmt.Errorf("aws Secrets Manager client is not initialized")
+	}
+
+	secretId, field := secret, ""
+	if i := strings.LastIndex(secret, secretFieldSeparator); i >= 0 {
+		secretId, field = secret[:i], secret[i+1:]
+	}
+
+	output, err := r.secretsManagerClient.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretId),
+	})
+	if err != nil {
+		return "", err
+	}
+	if output.SecretString == nil {
+		return "", fmt.Errorf("secret [%s] has no SecretString", secretId)
+	}
+
+	if field == "" {
+		return *output.SecretString, nil
+	}
+
+	var fields map[string]interface{}
+	err = json.Unmarshal([]byte(*output.SecretString), &fields)
+	if err != nil {
+		// do not wrap err, it may contain a part of the secret
+		return "", fmt.Errorf("secret [%s] is not a JSON object", secretId)
+	}
+	fieldValue, found := fields[field]
+	if !found {
+		return "", fmt.Errorf("secret [%s] has no field [%s]", secretId, field)
+	}
+	if s, ok := fieldValue.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(fieldValue)
+	if err != nil {
+		return "", fmt.Errorf("field [%s] of secret [%s] can not be encoded", field, secretId)
+	}
+	return string(b), nil
+}
+
+func (r *secretResolver) getParameter(ctx context.Context, name string) (string, error) {
+	if r.ssmClient == nil {
+		return "", fmt.Errorf("aws SSM client is not initialized")
+	}
+
+	output, err := r.ssmClient.GetParameterWithContext(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+	if output.Parameter == nil || output.Parameter.Value == nil {
+		return "", fmt.Errorf("parameter [%s] has no value", name)
+	}
+	return *output.Parameter.Value, nil
+}
@@ -0,0 +1,131 @@
+package appconfig
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/clock"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/clock/fakeclock"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockSecretsManager struct {
+	secretsmanageriface.SecretsManagerAPI
+	secrets map[string]string
+	calls   int
+}
+
+func (m *mockSecretsManager) GetSecretValueWithContext(_ aws.Context, input *secretsmanager.GetSecretValueInput, _ ...request.Option) (*secretsmanager.GetSecretValueOutput, error) {
+	m.calls++
+	secret, found := m.secrets[*input.SecretId]
+	if !found {
+		return nil, errors.New("ResourceNotFoundException")
+	}
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(secret)}, nil
+}
+
+type mockSSM struct {
+	ssmiface.SSMAPI
+	parameters map[string]string
+}
+
+func (m *mockSSM) GetParameterWithContext(_ aws.Context, input *ssm.GetParameterInput, _ ...request.Option) (*ssm.GetParameterOutput, error) {
+	parameter, found := m.parameters[*input.Name]
+	if !found {
+		return nil, errors.New("ParameterNotFound")
+	}
+	return &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String(parameter)}}, nil
+}
+
+func TestSecretResolver_Resolve(t *testing.T) {
+	arn := "arn:aws:secretsmanager:us-east-1:123456789012:secret:db-AbCdEf"
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "no reference",
+			content: `{"a": "${other:x}"}`,
+			want:    `{"a": "${other:x}"}`,
+		},
+		{
+			name:    "secrets manager field",
+			content: `{"user": "${secretsmanager:` + arn + `#username}", "password": "${secretsmanager:` + arn + `#password}"}`,
+			want:    `{"user": "admin", "password": "p\"w"}`,
+		},
+		{
+			name:    "secrets manager whole secret",
+			content: `token=${secretsmanager:token}`,
+			want:    `token=plain`,
+		},
+		{
+			name:    "ssm",
+			content: `{"key": "${ssm:/app/key}"}`,
+			want:    `{"key": "k1"}`,
+		},
+		{
+			name:    "missing field",
+			content: `{"a": "${secretsmanager:` + arn + `#missing}"}`,
+			wantErr: true,
+		},
+		{
+			name:    "missing parameter",
+			content: `{"a": "${ssm:/missing}"}`,
+			wantErr: true,
+		},
+	}
+
+	resolver := newSecretResolver(time.Minute, clock.New())
+	resolver.secretsManagerClient = &mockSecretsManager{
+		secrets: map[string]string{
+			arn:     `{"username": "admin", "password": "p\"w"}`,
+			"token": "plain",
+		},
+	}
+	resolver.ssmClient = &mockSSM{parameters: map[string]string{"/app/key": "k1"}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolver.resolve(context.TODO(), "test", tt.content)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSecretResolver_TTL(t *testing.T) {
+	secretsManager := &mockSecretsManager{secrets: map[string]string{"token": "v1"}}
+	clk := fakeclock.New(time.Now())
+	resolver := newSecretResolver(time.Minute, clk)
+	resolver.secretsManagerClient = secretsManager
+
+	got, err := resolver.resolve(context.TODO(), "test", "${secretsmanager:token}")
+	assert.Nil(t, err)
+	assert.Equal(t, "v1", got)
+
+	secretsManager.secrets["token"] = "v2"
+	got, err = resolver.resolve(context.TODO(), "test", "${secretsmanager:token}")
+	assert.Nil(t, err)
+	assert.Equal(t, "v1", got)
+	assert.Equal(t, 1, secretsManager.calls)
+
+	clk.Advance(time.Minute)
+	got, err = resolver.resolve(context.TODO(), "test", "${secretsmanager:token}")
+	assert.Nil(t, err)
+	assert.Equal(t, "v2", got)
+	assert.Equal(t, 2, secretsManager.calls)
+}
@@ -0,0 +1,73 @@
+package appconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// snapshotConfiguration is a cached configuration in a cache snapshot. Like
+// in the cache backend, only the raw content is written, so the snapshot does
+// not contain the resolved secrets.
+type snapshotConfiguration struct {
+	ClientConfigurationVersion string    `json:"clientConfigurationVersion"`
+	RawContent                 string    `json:"rawContent"`
+	FetchedAt                  time.Time `json:"fetchedAt"`
+}
+
+func (appConfig *EnhancedAppConfig) encodeSnapshot(configurationName string, configuration *EnhancedConfiguration) ([]byte, error) {
+	if configuration == nil || configuration.rawContent == nil {
+		return nil, fmt.Errorf("configuration [%s] has no raw content", configurationName)
+	}
+
+	fetchedAt, _ := appConfig.getFetched(configurationName)
+	return json.Marshal(snapshotConfiguration{
+		ClientConfigurationVersion: aws.StringValue(configuration.ClientConfigurationVersion),
+		RawContent:                 *configuration.rawContent,
+		FetchedAt:                  fetchedAt,
+	})
+}
+
+func (appConfig *EnhancedAppConfig) decodeSnapshot(configurationName string, data []byte) (*EnhancedConfiguration, error) {
+	var stored snapshotConfiguration
+	err := json.Unmarshal(data, &stored)
+	if err != nil {
+		return nil, err
+	}
+	if stored.FetchedAt.IsZero() {
+		return nil, fmt.Errorf("configuration [%s] has no fetched time", configurationName)
+	}
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), appConfig.timeout)
+	defer cancelFn()
+	configuration, err := appConfig.renderRawContent(ctx, configurationName, stored.ClientConfigurationVersion, stored.RawContent)
+	if err != nil {
+		return nil, err
+	}
+	configuration.IsCache = true
+	appConfig.setFetched(configurationName, stored.FetchedAt)
+	return configuration, nil
+}
+
+// SaveCacheSnapshot writes the cached configurations, e.g. to a file before
+// the process exits, see cache.Cache.Snapshot for the format.
+func (appConfig *EnhancedAppConfig) SaveCacheSnapshot(w io.Writer) error {
+	if appConfig.cache == nil {
+		return errors.New("cache is off")
+	}
+	return appConfig.cache.Snapshot(w)
+}
+
+// LoadCacheSnapshot adds the configurations saved by SaveCacheSnapshot to the
+// cache, the invalid ones are logged and skipped.
+func (appConfig *EnhancedAppConfig) LoadCacheSnapshot(r io.Reader) error {
+	if appConfig.cache == nil {
+		return errors.New("cache is off")
+	}
+	return appConfig.cache.Restore(r)
+}
@@ -0,0 +1,52 @@
+package appconfig
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot_SaveAndLoad(t *testing.T) {
+	appConfig := newTestAppConfig(t, "")
+	rawContent := `{"level": "info", "token": "${secretsmanager:token}"}`
+	configuration, err := appConfig.renderRawContent(context.TODO(), "my-config", "7", rawContent)
+	assert.Nil(t, err)
+	configuration.IsCache = true
+	appConfig.cache.Add("my-config", configuration)
+	fetchedAt := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	appConfig.setFetched("my-config", fetchedAt)
+	other, err := appConfig.renderRawContent(context.TODO(), "other", "1", `{"name": 1}`)
+	assert.Nil(t, err)
+	appConfig.cache.Add("other", other)
+	appConfig.setFetched("other", fetchedAt)
+	unfetched, err := appConfig.renderRawContent(context.TODO(), "unfetched", "1", `{"name": 1}`)
+	assert.Nil(t, err)
+	appConfig.cache.Add("unfetched", unfetched)
+
+	var buf bytes.Buffer
+	assert.Nil(t, appConfig.SaveCacheSnapshot(&buf))
+	// the resolved secret is not written
+	assert.NotContains(t, buf.String(), "plain")
+
+	// other does not match the schema of the new process and unfetched has no
+	// fetched time, they are skipped
+	restored := newTestAppConfig(t, "", WithSchema("other", `{"properties": {"name": {"type": "string"}}}`))
+	assert.Nil(t, restored.LoadCacheSnapshot(&buf))
+	assert.Equal(t, []string{"my-config"}, restored.cache.Keys())
+	restoredFetchedAt, found := restored.getFetched("my-config")
+	assert.True(t, found)
+	assert.True(t, fetchedAt.Equal(restoredFetchedAt))
+
+	configuration, err = restored.GetEnhancedConfiguration(context.TODO(), "my-config")
+	assert.Nil(t, err)
+	assert.True(t, configuration.IsCache)
+	assert.Equal(t, "7", aws.StringValue(configuration.ClientConfigurationVersion))
+	assert.JSONEq(t, `{"level": "debug", "token": "plain"}`, *configuration.Content)
+
+	restored.cache = nil
+	assert.NotNil(t, restored.SaveCacheSnapshot(&buf))
+}
@@ -0,0 +1,202 @@
+package appconfig
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/cache"
+	"github.com/hxy1991/aws-sdk-enhanced-go/awsenhanced/logger"
+)
+
+// Value is a configuration decoded into T and updated by the cache refresh,
+// with the "appconfig" tags if T has them, else encoding/json. Any valid
+// version different from the current one is applied, rollbacks included.
+type Value[T any] struct {
+	holder *valueHolder
+}
+
+func NewValue[T any](ctx context.Context, appConfig *EnhancedAppConfig, configurationName string) (*Value[T], error) {
+	if appConfig.cache == nil {
+		return nil, fmt.Errorf("value of configuration [%s] is refreshed with the cache, but the cache is off", configurationName)
+	}
+
+	holder, err := appConfig.newValueHolder(ctx, configurationName, func() interface{} {
+		return new(T)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Value[T]{holder: holder}, nil
+}
+
+// Load returns the last valid version.
+func (v *Value[T]) Load() T {
+	return *v.holder.load().(*T)
+}
+
+// Version returns the ClientConfigurationVersion of the value returned by Load.
+func (v *Value[T]) Version() string {
+	return v.holder.version()
+}
+
+// Err returns the error of the last refresh, nil if it has been applied.
+func (v *Value[T]) Err() error {
+	return v.holder.err()
+}
+
+// Close stops refreshing the value, Load keeps returning the last version.
+func (v *Value[T]) Close() {
+	v.holder.close()
+}
+
+// valueHolder keeps the last valid decoded version of a configuration.
+type valueHolder struct {
+	configurationName string
+	// log returns the logger of the client, nil for the default logger
+	log func() logger.Logger
+	// newValue returns a pointer to a new zero value to decode into
+	newValue func() interface{}
+	// unregister removes the holder from the listeners of the client
+	unregister func()
+
+	value          atomic.Value
+	currentVersion atomic.Value
+
+	// updateLock orders the updates, content is the one of the current version
+	updateLock sync.Mutex
+	content    string
+
+	lastErrLock sync.RWMutex
+	lastErr     error
+}
+
+func (appConfig *EnhancedAppConfig) newValueHolder(ctx context.Context, configurationName string, newValue func() interface{}) (*valueHolder, error) {
+	configuration, err := appConfig.GetEnhancedConfiguration(ctx, configurationName)
+	if err != nil {
+		return nil, err
+	}
+
+	holder := &valueHolder{
+		configurationName: configurationName,
+		log:               appConfig.log,
+		newValue:          newValue,
+	}
+
+	value := newValue()
+	err = decodeValue(configurationName, *configuration.Content, value)
+	if err != nil {
+		return nil, err
+	}
+	holder.store(value, configuration.ClientConfigurationVersion)
+	holder.content = *configuration.Content
+
+	appConfig.addListener(configurationName, holder)
+	holder.unregister = func() {
+		appConfig.removeListener(configurationName, holder)
+	}
+
+	return holder, nil
+}
+
+func (h *valueHolder) load() interface{} {
+	return h.value.Load()
+}
+
+func (h *valueHolder) version() string {
+	return h.currentVersion.Load().(string)
+}
+
+func (h *valueHolder) err() error {
+	h.lastErrLock.RLock()
+	defer h.lastErrLock.RUnlock()
+	return h.lastErr
+}
+
+func (h *valueHolder) close() {
+	if h.unregister != nil {
+		h.unregister()
+	}
+}
+
+func (h *valueHolder) update(configuration *EnhancedConfiguration) {
+	h.updateLock.Lock()
+	defer h.updateLock.Unlock()
+
+	// the content of a version changes when its secrets or overrides are rendered again
+	if aws.StringValue(configuration.ClientConfigurationVersion) == h.version() && *configuration.Content == h.content {
+		return
+	}
+
+	value := h.newValue()
+	err := decodeValue(h.configurationName, *configuration.Content, value)
+
+	h.lastErrLock.Lock()
+	h.lastErr = err
+	h.lastErrLock.Unlock()
+
+	if err != nil {
+		h.logger().Error("refresh value of configuration fail, keep the last valid version",
+			logger.Configuration(h.configurationName), logger.Version(h.version()), logger.Err(err))
+		return
+	}
+
+	h.store(value, configuration.ClientConfigurationVersion)
+	h.content = *configuration.Content
+}
+
+// evicted is called when the configuration falls out of the cache, the
+// refresh ticker keeps fetching it for the holder.
+func (h *valueHolder) evicted(reason cache.EvictionReason) {
+	h.logger().Info("configuration has been removed from the cache, it is refreshed for its value",
+		logger.Configuration(h.configurationName), logger.Any("reason", reason.String()))
+}
+
+// stopped is called when the cache is turned off, which stops the refreshes.
+func (h *valueHolder) stopped() {
+	err := fmt.Errorf("cache is off, configuration [%s] is not refreshed", h.configurationName)
+
+	h.lastErrLock.Lock()
+	h.lastErr = err
+	h.lastErrLock.Unlock()
+
+	h.logger().Warn("cache is off, configuration is not refreshed", logger.Configuration(h.configurationName))
+}
+
+func (h *valueHolder) logger() logger.Logger {
+	if h.log == nil {
+		return logger.Default()
+	}
+	return h.log()
+}
+
+func (h *valueHolder) store(value interface{}, version *string) {
+	h.value.Store(value)
+	if version != nil {
+		h.currentVersion.Store(*version)
+	} else {
+		h.currentVersion.Store("")
+	}
+}
+
+// decodeValue decodes the content into target, a pointer.
+func decodeValue(configurationName string, content string, target interface{}) error {
+	switch t := target.(type) {
+	case *string:
+		*t = content
+		return nil
+	case *[]byte:
+		*t = []byte(content)
+		return nil
+	}
+
+	if targetType := reflect.TypeOf(target).Elem(); targetType.Kind() == reflect.Struct && hasBindTags(targetType) {
+		return decodeStruct(configurationName, content, target)
+	}
+
+	return json.Unmarshal([]byte(content), target)
+}
@@ -0,0 +1,177 @@
+package appconfig
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValue_DecodeValue(t *testing.T) {
+	content := `{"name": "foo", "database": {"host": "db"}}`
+
+	var s string
+	assert.Nil(t, decodeValue("test", content, &s))
+	assert.Equal(t, content, s)
+
+	var b []byte
+	assert.Nil(t, decodeValue("test", content, &b))
+	assert.Equal(t, content, string(b))
+
+	var m map[string]interface{}
+	assert.Nil(t, decodeValue("test", content, &m))
+	assert.Equal(t, "foo", m["name"])
+
+	var c bindConfig
+	assert.Nil(t, decodeValue("test", content, &c))
+	assert.Equal(t, 5432, c.Database.Port)
+
+	var invalid bindConfig
+	assert.NotNil(t, decodeValue("test", `{"name": "foo"}`, &invalid))
+
+	// a struct without "appconfig" tags is decoded with encoding/json
+	var j struct {
+		Name     string `json:"name"`
+		Database struct {
+			Host string `json:"host"`
+		} `json:"database"`
+	}
+	assert.Nil(t, decodeValue("test", content, &j))
+	assert.Equal(t, "foo", j.Name)
+	assert.Equal(t, "db", j.Database.Host)
+}
+
+func TestValue_Update(t *testing.T) {
+	value := newTestValue[bindConfig](t, `{"name": "foo", "database": {"host": "db1"}}`, "1")
+	assert.Equal(t, "db1", value.Load().Database.Host)
+
+	invalidContent := `{"name": "foo"}`
+	invalidVersion := "2"
+	value.holder.update(&EnhancedConfiguration{ClientConfigurationVersion: &invalidVersion, Content: &invalidContent})
+	assert.NotNil(t, value.Err())
+	assert.Equal(t, "1", value.Version())
+	assert.Equal(t, "db1", value.Load().Database.Host)
+
+	validContent := `{"name": "foo", "database": {"host": "db3"}}`
+	validVersion := "3"
+	value.holder.update(&EnhancedConfiguration{ClientConfigurationVersion: &validVersion, Content: &validContent})
+	assert.Nil(t, value.Err())
+	assert.Equal(t, "3", value.Version())
+	assert.Equal(t, "db3", value.Load().Database.Host)
+}
+
+func TestValue_LoadNoAllocation(t *testing.T) {
+	value := newTestValue[bindConfig](t, `{"name": "foo", "database": {"host": "db"}}`, "1")
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = value.Load()
+	})
+	assert.Equal(t, float64(0), allocs)
+}
+
+func BenchmarkValue_Load(b *testing.B) {
+	value := newTestValue[bindConfig](b, `{"name": "foo", "database": {"host": "db"}}`, "1")
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = value.Load()
+		}
+	})
+}
+
+func newTestValue[T any](t testing.TB, content string, version string) *Value[T] {
+	holder := &valueHolder{
+		configurationName: "test",
+		newValue: func() interface{} {
+			return new(T)
+		},
+	}
+	value := holder.newValue()
+	assert.Nil(t, decodeValue("test", content, value))
+	holder.store(value, &version)
+	holder.content = content
+	return &Value[T]{holder: holder}
+}
+
+func TestValue_UpdateOrder(t *testing.T) {
+	value := newTestValue[bindConfig](t, `{"name": "foo", "database": {"host": "db2"}}`, "2")
+
+	// the same version with the same content is not decoded again
+	sameContent := `{"name": "foo", "database": {"host": "db2"}}`
+	value.holder.update(&EnhancedConfiguration{ClientConfigurationVersion: aws.String("2"), Content: &sameContent})
+	assert.Equal(t, "2", value.Version())
+	assert.Nil(t, value.Err())
+
+	// the same version re-rendered with other secrets or overrides
+	renderedContent := `{"name": "foo", "database": {"host": "db2-rendered"}}`
+	value.holder.update(&EnhancedConfiguration{ClientConfigurationVersion: aws.String("2"), Content: &renderedContent})
+	assert.Equal(t, "2", value.Version())
+	assert.Equal(t, "db2-rendered", value.Load().Database.Host)
+
+	newerContent := `{"name": "foo", "database": {"host": "db10"}}`
+	value.holder.update(&EnhancedConfiguration{ClientConfigurationVersion: aws.String("10"), Content: &newerContent})
+	assert.Equal(t, "10", value.Version())
+	assert.Equal(t, "db10", value.Load().Database.Host)
+
+	// a rollback of AWS AppConfig to an older version
+	olderContent := `{"name": "foo", "database": {"host": "db1"}}`
+	value.holder.update(&EnhancedConfiguration{ClientConfigurationVersion: aws.String("1"), Content: &olderContent})
+	assert.Equal(t, "1", value.Version())
+	assert.Equal(t, "db1", value.Load().Database.Host)
+}
+
+func TestValue_Evicted(t *testing.T) {
+	var lock sync.Mutex
+	version, content := "1", `{"name": "foo", "database": {"host": "db1"}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		w.Header().Set("Configuration-Version", version)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("client_configuration_version") != version {
+			_, _ = w.Write([]byte(content))
+		}
+	}))
+	defer server.Close()
+
+	appConfig := newTestAppConfig(t, server.URL)
+
+	value, err := NewValue[bindConfig](context.Background(), appConfig, "server")
+	assert.Nil(t, err)
+	assert.Equal(t, "db1", value.Load().Database.Host)
+
+	// the value is still refreshed after its configuration has been evicted
+	appConfig.cache.Delete("server")
+	assert.Equal(t, []string{"server"}, appConfig.uncachedListenedKeys(appConfig.cache.Keys()))
+
+	lock.Lock()
+	version, content = "2", `{"name": "foo", "database": {"host": "db2"}}`
+	lock.Unlock()
+	assert.Nil(t, appConfig.refresh(context.Background(), "server"))
+	assert.Nil(t, value.Err())
+	assert.Equal(t, "2", value.Version())
+	assert.Equal(t, "db2", value.Load().Database.Host)
+	_, found := appConfig.cache.Get("server")
+	assert.False(t, found)
+
+	value.Close()
+	assert.Empty(t, appConfig.uncachedListenedKeys(nil))
+}
+
+func TestValue_CacheOff(t *testing.T) {
+	appConfig := newTestAppConfig(t, "")
+	value := &valueHolder{configurationName: "server"}
+	appConfig.addListener("server", value)
+
+	assert.Nil(t, appConfig.ApplyWithOptions(WithIsCache(false)))
+	assert.NotNil(t, value.err())
+
+	_, err := NewValue[bindConfig](context.Background(), appConfig, "server")
+	assert.NotNil(t, err)
+}